@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ---------- ANOMALIES ----------
+
+// maxSessionHours flags sessions long enough to be a forgotten clock-out.
+const maxSessionHours = 16
+
+// anomaly is a dtr record that needs an admin's attention.
+type anomaly struct {
+	DTRID     int    `json:"dtr_id"`
+	FacultyID int    `json:"faculty_id"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Detail    string `json:"detail"`
+}
+
+// findAnomalies checks sessions clocked in within [from, to) for open,
+// negative-length, and overly long sessions, plus rows clocked out within the
+// range that have no clock-in at all. Open sessions that started today are
+// still in progress and not reported.
+func findAnomalies(from, to time.Time) ([]anomaly, error) {
+	sessions, err := querySessions(from, to, 0)
+	if err != nil {
+		return nil, err
+	}
+	names, err := facultyNames()
+	if err != nil {
+		return nil, err
+	}
+
+	today := dayStart(appNow())
+	var out []anomaly
+	for _, s := range sessions {
+		a := anomaly{DTRID: s.ID, FacultyID: s.FacultyID, Name: names[s.FacultyID]}
+		in := s.In.In(appLoc)
+		switch {
+		case !s.Out.Valid && in.Before(today):
+			a.Kind = "open session"
+			a.Detail = fmt.Sprintf("clocked in %s with no clock-out", in.Format("2006-01-02 15:04"))
+		case !s.Out.Valid:
+			continue
+		case s.Out.Time.Before(s.In):
+			a.Kind = "negative duration"
+			a.Detail = fmt.Sprintf("out %s is before in %s", s.Out.Time.In(appLoc).Format("2006-01-02 15:04"), in.Format("2006-01-02 15:04"))
+		case s.Out.Time.Sub(s.In).Hours() > maxSessionHours:
+			a.Kind = "long session"
+			a.Detail = fmt.Sprintf("%.1f hours starting %s", s.Out.Time.Sub(s.In).Hours(), in.Format("2006-01-02 15:04"))
+		default:
+			continue
+		}
+		out = append(out, a)
+	}
+
+	outOnly, err := findOutWithoutIn(from, to, names)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, outOnly...), nil
+}
+
+// findOutWithoutIn reports dtr rows with an out_time in [from, to) but no
+// in_time. Scans never create these, but imports and manual fixes can.
+func findOutWithoutIn(from, to time.Time, names map[int]string) ([]anomaly, error) {
+	rs, err := db.Query("SELECT id, faculty_id, out_time FROM dtr WHERE in_time IS NULL AND out_time >= ? AND out_time < ? ORDER BY out_time", from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []anomaly
+	for rs.Next() {
+		var a anomaly
+		var outTime time.Time
+		if err := rs.Scan(&a.DTRID, &a.FacultyID, &outTime); err != nil {
+			return nil, err
+		}
+		a.Name = names[a.FacultyID]
+		a.Kind = "out without in"
+		a.Detail = fmt.Sprintf("clocked out %s with no clock-in", outTime.In(appLoc).Format("2006-01-02 15:04"))
+		out = append(out, a)
+	}
+	return out, rs.Err()
+}
+
+// facultyNames maps faculty ids to names.
+func facultyNames() (map[int]string, error) {
+	rs, err := db.Query("SELECT id, name FROM faculty")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	names := map[int]string{}
+	for rs.Next() {
+		var id int
+		var name string
+		if err := rs.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rs.Err()
+}
+
+// staleSession is a clock-in that has stayed open past the threshold.
+type staleSession struct {
+	DTRID     int     `json:"dtr_id"`
+	FacultyID int     `json:"faculty_id"`
+	Name      string  `json:"name"`
+	In        string  `json:"in"`
+	OpenHours float64 `json:"open_hours"`
+}
+
+// findStaleOpen lists open sessions clocked in more than threshold before now,
+// longest open first.
+func findStaleOpen(now time.Time, threshold time.Duration) ([]staleSession, error) {
+	rs, err := db.Query(`SELECT d.id, d.faculty_id, f.name, d.in_time FROM dtr d
+		JOIN faculty f ON f.id = d.faculty_id
+		WHERE d.out_time IS NULL AND d.in_time < ? ORDER BY d.in_time`, now.Add(-threshold))
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	out := []staleSession{}
+	for rs.Next() {
+		var s staleSession
+		var in time.Time
+		if err := rs.Scan(&s.DTRID, &s.FacultyID, &s.Name, &in); err != nil {
+			return nil, err
+		}
+		s.In = in.In(appLoc).Format("2006-01-02 15:04")
+		s.OpenHours = math.Round(now.Sub(in).Hours()*10) / 10
+		out = append(out, s)
+	}
+	return out, rs.Err()
+}
+
+// handleStaleOpen reports sessions left open longer than ?hours= (default 12)
+// as of the current Manila time, for chasing forgotten clock-outs.
+func handleStaleOpen(w http.ResponseWriter, r *http.Request) {
+	hours := 12.0
+	if v := r.FormValue("hours"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid hours")
+			return
+		}
+		hours = h
+	}
+	stale, err := findStaleOpen(appNow(), time.Duration(hours*float64(time.Hour)))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, stale)
+}
+
+// restViolation is a session that began less than the minimum rest after
+// the faculty's previous session ended.
+type restViolation struct {
+	FacultyID int     `json:"faculty_id"`
+	Name      string  `json:"name"`
+	LastOut   string  `json:"last_out"`
+	NextIn    string  `json:"next_in"`
+	RestHours float64 `json:"rest_hours"`
+}
+
+// findShortRest walks each faculty's sessions in clock-in order and compares
+// every clock-in in [from, to) with the previous session's clock-out, across
+// day boundaries. Each faculty's last session before from is loaded however
+// long ago it was, so the first session in range has something to compare
+// against. A previous session still open or clocked out before it was
+// clocked in has no usable clock-out and is skipped.
+func findShortRest(from, to time.Time, minRest time.Duration) ([]restViolation, error) {
+	prior, err := lastSessionsBefore(from)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := querySessions(from, to, 0)
+	if err != nil {
+		return nil, err
+	}
+	names, err := facultyNames()
+	if err != nil {
+		return nil, err
+	}
+	prev := map[int]session{}
+	for _, s := range prior {
+		prev[s.FacultyID] = s
+	}
+
+	out := []restViolation{}
+	for _, s := range sessions {
+		p, ok := prev[s.FacultyID]
+		prev[s.FacultyID] = s
+		if !ok || !p.Out.Valid || p.Out.Time.Before(p.In) {
+			continue
+		}
+		if rest := s.In.Sub(p.Out.Time); rest < minRest {
+			out = append(out, restViolation{
+				FacultyID: s.FacultyID,
+				Name:      names[s.FacultyID],
+				LastOut:   p.Out.Time.In(appLoc).Format("2006-01-02 15:04"),
+				NextIn:    s.In.In(appLoc).Format("2006-01-02 15:04"),
+				RestHours: math.Round(rest.Hours()*10) / 10,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].NextIn != out[j].NextIn {
+			return out[i].NextIn < out[j].NextIn
+		}
+		return out[i].FacultyID < out[j].FacultyID
+	})
+	return out, nil
+}
+
+// lastSessionsBefore returns each faculty's latest session clocked in before t.
+// Sessions sharing that clock-in, as a double import leaves, all match; they
+// come in id order so a caller keeping the last per faculty gets the newest row.
+func lastSessionsBefore(t time.Time) ([]session, error) {
+	rs, err := db.Query(`SELECT id, faculty_id, in_time, out_time, COALESCE(overtime_approved, 0) FROM dtr d
+		WHERE in_time = (SELECT MAX(in_time) FROM dtr WHERE faculty_id = d.faculty_id AND in_time < ?)
+		ORDER BY id`, t)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []session
+	for rs.Next() {
+		var s session
+		if err := rs.Scan(&s.ID, &s.FacultyID, &s.In, &s.Out, &s.OvertimeApproved); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rs.Err()
+}
+
+// handleShortRest reports sessions that began less than ?hours= (default
+// minRestHours) after the faculty's previous session ended, over a payroll
+// range.
+func handleShortRest(w http.ResponseWriter, r *http.Request) {
+	hours := minRestHours
+	if v := r.FormValue("hours"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid hours")
+			return
+		}
+		hours = h
+	}
+	start, end, err := parsePayrollRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	found, err := findShortRest(start, end.AddDate(0, 0, 1), time.Duration(hours*float64(time.Hour)))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindStaleOpen(t *testing.T) {
+	now := at(t, "2026-10-14 20:00")
+	tests := []struct {
+		name  string
+		in    string
+		stale bool
+	}{
+		{"just under", "2026-10-14 08:01", false},
+		{"exactly at", "2026-10-14 08:00", false},
+		{"just over", "2026-10-14 07:59", true},
+		{"days open", "2026-10-11 07:00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			addSession(t, fid, tt.in, "")
+			// a closed session is never stale however old
+			addSession(t, fid, "2026-10-01 08:00", "2026-10-01 12:00")
+			got, err := findStaleOpen(now, 12*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(got) == 1) != tt.stale || len(got) > 1 {
+				t.Fatalf("got %d stale sessions, want stale=%v", len(got), tt.stale)
+			}
+			if tt.stale {
+				want := now.Sub(at(t, tt.in)).Hours()
+				if got[0].FacultyID != fid || got[0].Name != "Ana Cruz" || got[0].In != tt.in || got[0].OpenHours < want-0.05 || got[0].OpenHours > want+0.05 {
+					t.Errorf("got %+v, want %s open %.1f hours", got[0], tt.in, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindOutWithoutIn(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addSession(t, fid, "2026-10-13 08:00", "2026-10-13 17:00")
+	for _, out := range []string{"2026-10-14 17:00", "2026-10-20 17:00"} {
+		if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?, NULL, ?)", fid, at(t, out)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{"in range", "2026-10-12 00:00", "2026-10-19 00:00", []string{"out without in: clocked out 2026-10-14 17:00 with no clock-in"}},
+		{"both", "2026-10-12 00:00", "2026-10-21 00:00", []string{
+			"out without in: clocked out 2026-10-14 17:00 with no clock-in",
+			"out without in: clocked out 2026-10-20 17:00 with no clock-in",
+		}},
+		{"out of range", "2026-10-01 00:00", "2026-10-08 00:00", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := findAnomalies(at(t, tt.from), at(t, tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range found {
+				if a.FacultyID != fid || a.Name != "Ana Cruz" {
+					t.Errorf("anomaly for %d %q", a.FacultyID, a.Name)
+				}
+				got = append(got, a.Kind+": "+a.Detail)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutWithoutInStillClocksIn(t *testing.T) {
+	resetDB(t)
+	fid, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addOutOnly(t, fid, "2026-10-14 17:00")
+
+	rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}})
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "Clock IN") {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if n := count(t, "SELECT COUNT(*) FROM dtr WHERE faculty_id=? AND in_time IS NOT NULL AND out_time IS NULL", fid); n != 1 {
+		t.Errorf("%d open sessions after the scan, want 1", n)
+	}
+}
+
+func TestFindShortRest(t *testing.T) {
+	type sess struct{ in, out string }
+	tests := []struct {
+		name     string
+		sessions []sess
+		minRest  time.Duration
+		want     []string
+	}{
+		{"across midnight", []sess{
+			{"2026-10-13 14:00", "2026-10-13 22:00"},
+			{"2026-10-14 05:00", "2026-10-14 09:00"},
+		}, 8 * time.Hour, []string{"2026-10-13 22:00 -> 2026-10-14 05:00 (7.0h)"}},
+		{"enough rest", []sess{
+			{"2026-10-13 14:00", "2026-10-13 22:00"},
+			{"2026-10-14 06:00", "2026-10-14 09:00"},
+		}, 8 * time.Hour, nil},
+		{"same day", []sess{
+			{"2026-10-14 00:00", "2026-10-14 06:00"},
+			{"2026-10-14 09:00", "2026-10-14 17:00"},
+		}, 8 * time.Hour, []string{"2026-10-14 06:00 -> 2026-10-14 09:00 (3.0h)"}},
+		{"previous session days before range", []sess{
+			{"2026-10-08 08:00", "2026-10-08 17:00"},
+			{"2026-10-13 08:00", "2026-10-13 17:00"},
+		}, 120 * time.Hour, []string{"2026-10-08 17:00 -> 2026-10-13 08:00 (111.0h)"}},
+		{"open previous session", []sess{
+			{"2026-10-13 20:00", ""},
+			{"2026-10-14 05:00", "2026-10-14 09:00"},
+		}, 8 * time.Hour, nil},
+		{"duplicate previous clock-in", []sess{
+			{"2026-10-12 08:00", "2026-10-12 12:00"},
+			{"2026-10-12 08:00", "2026-10-12 22:00"},
+			{"2026-10-13 05:00", "2026-10-13 09:00"},
+		}, 8 * time.Hour, []string{"2026-10-12 22:00 -> 2026-10-13 05:00 (7.0h)"}},
+		{"both before range", []sess{
+			{"2026-10-11 14:00", "2026-10-11 22:00"},
+			{"2026-10-12 05:00", "2026-10-12 09:00"},
+		}, 8 * time.Hour, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			for _, s := range tt.sessions {
+				addSession(t, fid, s.in, s.out)
+			}
+			found, err := findShortRest(at(t, "2026-10-13 00:00"), at(t, "2026-10-20 00:00"), tt.minRest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range found {
+				if v.FacultyID != fid || v.Name != "Ana Cruz" {
+					t.Errorf("violation for %d %q", v.FacultyID, v.Name)
+				}
+				got = append(got, fmt.Sprintf("%s -> %s (%.1fh)", v.LastOut, v.NextIn, v.RestHours))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
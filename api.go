@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- API ----------
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// apiError is the body of every API error response:
+// {"error":{"code":"not_found","message":"Faculty not found"}}.
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// writeJSONError answers an API request with status and the apiError
+// envelope. The code is the snake_cased status text, e.g. "bad_request".
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	var e apiError
+	e.Error.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	e.Error.Message = message
+	writeJSON(w, status, e)
+}
+
+// handleVerifyToken tells a card validator whether a token belongs to an
+// active faculty without recording anything.
+func handleVerifyToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/api/verify/")
+
+	type result struct {
+		Valid bool   `json:"valid"`
+		Name  string `json:"name,omitempty"`
+	}
+	var name string
+	var active bool
+	err := db.QueryRow("SELECT name, active FROM faculty WHERE token=?", token).Scan(&name, &active)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Unknown token")
+		return
+	}
+	writeJSON(w, http.StatusOK, result{Valid: active, Name: name})
+}
+
+// scanEvent is one clock-in or clock-out in the recent scans feed.
+type scanEvent struct {
+	FacultyID int    `json:"faculty_id"`
+	Name      string `json:"name"`
+	Action    string `json:"action"` // "IN" or "OUT"
+	Time      string `json:"time"`
+	DeviceID  string `json:"device_id,omitempty"`
+	at        time.Time
+}
+
+// recentScans returns the latest limit clock-in/out events, newest first.
+// Each dtr row is an IN at in_time and, once closed, an OUT at out_time,
+// each with the device that recorded it.
+func recentScans(limit int) ([]scanEvent, error) {
+	var events []scanEvent
+	for _, q := range []struct{ action, col, device string }{{"IN", "in_time", "device_id"}, {"OUT", "out_time", "out_device_id"}} {
+		rs, err := db.Query(`SELECT d.faculty_id, f.name, d.`+q.col+`, COALESCE(d.`+q.device+`, '') FROM dtr d
+			JOIN faculty f ON f.id = d.faculty_id
+			WHERE d.`+q.col+` IS NOT NULL ORDER BY d.`+q.col+` DESC LIMIT ?`, limit)
+		if err != nil {
+			return nil, err
+		}
+		for rs.Next() {
+			e := scanEvent{Action: q.action}
+			if err := rs.Scan(&e.FacultyID, &e.Name, &e.at, &e.DeviceID); err != nil {
+				rs.Close()
+				return nil, err
+			}
+			e.Time = e.at.In(appLoc).Format(time.RFC3339)
+			events = append(events, e)
+		}
+		rs.Close()
+		if err := rs.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.After(events[j].at) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// handleRecentScans feeds a live ticker with the latest ?limit= (default 20,
+// max 100) scan events.
+func handleRecentScans(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, 100)
+	}
+	events, err := recentScans(limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if events == nil {
+		events = []scanEvent{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// distinctRoles lists existing faculty roles, de-duplicated case-insensitively.
+// Where spellings differ only in case, the most used one is kept.
+func distinctRoles() ([]string, error) {
+	rs, err := db.Query("SELECT TRIM(role), COUNT(*) AS n FROM faculty WHERE TRIM(COALESCE(role, '')) != '' GROUP BY TRIM(role) ORDER BY n DESC, TRIM(role)")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	seen := map[string]bool{}
+	roles := []string{}
+	for rs.Next() {
+		var role string
+		var n int
+		if err := rs.Scan(&role, &n); err != nil {
+			return nil, err
+		}
+		if key := normalizeRole(role); !seen[key] {
+			seen[key] = true
+			roles = append(roles, role)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return normalizeRole(roles[i]) < normalizeRole(roles[j]) })
+	return roles, rs.Err()
+}
+
+// handleRoles feeds the role autocomplete on the faculty forms: the allowed
+// roles when ALLOWED_ROLES is set, else the roles already in use.
+func handleRoles(w http.ResponseWriter, r *http.Request) {
+	if len(allowedRoles) > 0 {
+		writeJSON(w, http.StatusOK, allowedRoles)
+		return
+	}
+	roles, err := distinctRoles()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, roles)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	resetDB(t)
+	_, active := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	inactiveID, inactive := addFaculty(t, "Ben Reyes", "Teacher", 100)
+	if _, err := db.Exec("UPDATE faculty SET active=0 WHERE id=?", inactiveID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+		body  string
+	}{
+		{"valid", active, http.StatusOK, `{"valid":true,"name":"Ana Cruz"}`},
+		{"inactive", inactive, http.StatusOK, `{"valid":false,"name":"Ben Reyes"}`},
+		{"unknown", "nosuchtoken", http.StatusNotFound, `"Unknown token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleVerifyToken(rec, httptest.NewRequest("GET", "/api/verify/"+tt.token, nil))
+			if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("got %d %s, want %d with %s", rec.Code, rec.Body, tt.want, tt.body)
+			}
+		})
+	}
+	if n := count(t, "SELECT COUNT(*) FROM dtr"); n != 0 {
+		t.Errorf("verifying created %d dtr records", n)
+	}
+}
+
+func TestRecentScans(t *testing.T) {
+	resetDB(t)
+	ana, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	ben, _ := addFaculty(t, "Ben Reyes", "Teacher", 100)
+	addSession(t, ana, "2026-10-14 07:50", "2026-10-14 12:05")
+	addSession(t, ben, "2026-10-14 08:10", "")
+	addSession(t, ana, "2026-10-14 13:00", "")
+
+	tests := []struct {
+		limit string
+		want  []string
+	}{
+		{"", []string{"Ana Cruz IN 13:00", "Ana Cruz OUT 12:05", "Ben Reyes IN 08:10", "Ana Cruz IN 07:50"}},
+		{"2", []string{"Ana Cruz IN 13:00", "Ana Cruz OUT 12:05"}},
+		{"1", []string{"Ana Cruz IN 13:00"}},
+	}
+	for _, tt := range tests {
+		t.Run("limit "+tt.limit, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleRecentScans(rec, httptest.NewRequest("GET", "/api/recent-scans?limit="+tt.limit, nil))
+			var events []scanEvent
+			if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+				t.Fatalf("status %d: %v", rec.Code, err)
+			}
+			var got []string
+			for _, e := range events {
+				ts, _ := time.Parse(time.RFC3339, e.Time)
+				got = append(got, e.Name+" "+e.Action+" "+ts.In(appLoc).Format("15:04"))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handleRecentScans(rec, httptest.NewRequest("GET", "/api/recent-scans?limit=zero", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit: got %d, want 400", rec.Code)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		allowed []string
+		want    []string
+	}{
+		{"case duplicates keep the common spelling", []string{"Teacher", "Teacher", "teacher", "TEACHER ", "Staff"}, nil, []string{"Staff", "Teacher"}},
+		{"blank roles skipped", []string{"", "  ", "Staff"}, nil, []string{"Staff"}},
+		{"none yet", nil, nil, []string{}},
+		{"allowed roles win", []string{"teacher"}, []string{"Faculty", "Staff"}, []string{"Faculty", "Staff"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &allowedRoles, tt.allowed)
+			for i, role := range tt.roles {
+				addFaculty(t, fmt.Sprintf("Faculty %d", i), role, 100)
+			}
+			rec := httptest.NewRecorder()
+			handleRoles(rec, httptest.NewRequest("GET", "/api/roles", nil))
+			var got []string
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("status %d: %v", rec.Code, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || got == nil {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONErrorEnvelope(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		path    string
+		status  int
+		code    string
+	}{
+		{"unknown token", handleVerifyToken, "GET", "/api/verify/nosuchtoken", http.StatusNotFound, "not_found"},
+		{"unknown faculty", handleFacultyAPI, "GET", "/api/faculty/999999/monthly", http.StatusNotFound, "not_found"},
+		{"bad year", handleFacultyAPI, "GET", fmt.Sprintf("/api/faculty/%d/monthly?year=abc", fid), http.StatusBadRequest, "bad_request"},
+		{"bad limit", handleRecentScans, "GET", "/api/scans/recent?limit=-1", http.StatusBadRequest, "bad_request"},
+		{"bad date", handleNotYetIn, "GET", "/report/not-yet-in?date=someday", http.StatusBadRequest, "bad_request"},
+		{"wrong method", handleVerifyToken, "POST", "/api/verify/x", http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type %q", ct)
+			}
+			var body map[string]map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("not the error envelope: %s", rec.Body)
+			}
+			e, ok := body["error"]
+			if !ok || len(body) != 1 || e["code"] != tt.code || e["message"] == "" {
+				t.Errorf("got %s, want {\"error\":{\"code\":%q,\"message\":...}}", rec.Body, tt.code)
+			}
+		})
+	}
+}
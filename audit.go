@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ---------- AUDIT LOG ----------
+
+type auditEntry struct {
+	ID     int
+	At     time.Time
+	Actor  string
+	Action string
+	Target string
+	Detail string
+}
+
+// audit records an admin action; failures are logged but never block the action.
+func audit(r *http.Request, action, target, detail string) {
+	session, _ := store.Get(r, "session")
+	actor, _ := session.Values["username"].(string)
+	_, err := db.Exec("INSERT INTO audit_log (at, actor, action, target, detail) VALUES (?,?,?,?,?)",
+		appNow(), actor, action, target, detail)
+	if err != nil {
+		logf(r, "audit: %v", err)
+	}
+}
+
+// auditFilter narrows the audit log; zero values match everything.
+type auditFilter struct {
+	Actor string
+	From  time.Time // inclusive day
+	To    time.Time // inclusive day
+}
+
+func (f auditFilter) where() (string, []any) {
+	q := " WHERE 1=1"
+	var args []any
+	if f.Actor != "" {
+		q += " AND actor = ?"
+		args = append(args, f.Actor)
+	}
+	if !f.From.IsZero() {
+		q += " AND at >= ?"
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		q += " AND at < ?"
+		args = append(args, f.To.AddDate(0, 0, 1))
+	}
+	return q, args
+}
+
+// queryAudit returns one page of matching entries, newest first, plus the total match count.
+func queryAudit(f auditFilter, limit, offset int) ([]auditEntry, int, error) {
+	where, args := f.where()
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	q := "SELECT id, at, actor, action, target, detail FROM audit_log" + where + " ORDER BY id DESC"
+	if limit > 0 {
+		q += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+	rs, err := db.Query(q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rs.Close()
+
+	var out []auditEntry
+	for rs.Next() {
+		var e auditEntry
+		if err := rs.Scan(&e.ID, &e.At, &e.Actor, &e.Action, &e.Target, &e.Detail); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, e)
+	}
+	return out, total, rs.Err()
+}
+
+// parseAuditFilter reads actor/from/to query params.
+func parseAuditFilter(r *http.Request) (auditFilter, error) {
+	f := auditFilter{Actor: r.FormValue("actor")}
+	var err error
+	if s := r.FormValue("from"); s != "" {
+		if f.From, err = time.ParseInLocation("2006-01-02", s, appLoc); err != nil {
+			return f, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", s)
+		}
+	}
+	if s := r.FormValue("to"); s != "" {
+		if f.To, err = time.ParseInLocation("2006-01-02", s, appLoc); err != nil {
+			return f, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", s)
+		}
+	}
+	return f, nil
+}
+
+const auditMaxPerPage = 200
+
+func handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.FormValue("per_page"))
+	if perPage < 1 {
+		perPage = 50
+	}
+	if perPage > auditMaxPerPage {
+		perPage = auditMaxPerPage
+	}
+
+	entries, total, err := queryAudit(f, perPage, (page-1)*perPage)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	// links keep the current filters and only change the page
+	link := func(p int) string {
+		q := url.Values{}
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		for _, k := range []string{"actor", "from", "to"} {
+			if v := r.FormValue(k); v != "" {
+				q.Set(k, v)
+			}
+		}
+		return "/admin/audit?" + q.Encode()
+	}
+	var prev, next string
+	if page > 1 {
+		prev = link(page - 1)
+	}
+	if page*perPage < total {
+		next = link(page + 1)
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	tplAudit.Execute(w, struct {
+		branding
+		Entries          []auditEntry
+		Total, Page      int
+		Actor, From, To  string
+		PrevURL, NextURL string
+	}{
+		branding: pageBranding(),
+		Entries:  entries,
+		Total:    total,
+		Page:     page,
+		Actor:    r.FormValue("actor"),
+		From:     r.FormValue("from"),
+		To:       r.FormValue("to"),
+		PrevURL:  prev,
+		NextURL:  next,
+	})
+}
+
+// handleAuditCSV exports every audit entry matching the same actor/from/to
+// filters as the HTML view, newest first.
+func handleAuditCSV(w http.ResponseWriter, r *http.Request) {
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, _, err := queryAudit(f, 0, 0)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment;filename=audit-log.csv")
+	csvw := csv.NewWriter(w)
+	defer csvw.Flush()
+
+	csvw.Write([]string{"Timestamp", "Actor", "Action", "Target", "Detail"})
+	for _, e := range entries {
+		csvw.Write([]string{e.At.In(appLoc).Format("2006-01-02 15:04:05"), e.Actor, e.Action, e.Target, e.Detail})
+	}
+}
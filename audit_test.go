@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+// seedAudit logs one entry a day from 2026-10-01 for each of actors, with
+// targets t1, t2, ...
+func seedAudit(t *testing.T, actors ...string) {
+	t.Helper()
+	for i, a := range actors {
+		if _, err := db.Exec("INSERT INTO audit_log (at, actor, action, target, detail) VALUES (?,?,?,?,?)",
+			at(t, fmt.Sprintf("2026-10-%02d 09:00", i+1)), a, "faculty.edit", fmt.Sprintf("t%d", i+1), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueryAudit(t *testing.T) {
+	resetDB(t)
+	seedAudit(t, "alice", "bob", "alice", "alice", "bob", "alice", "alice")
+
+	tests := []struct {
+		name          string
+		filter        auditFilter
+		limit, offset int
+		total         int
+		want          string
+	}{
+		{"first page", auditFilter{}, 3, 0, 7, "[t7 t6 t5]"},
+		{"second page", auditFilter{}, 3, 3, 7, "[t4 t3 t2]"},
+		{"last page", auditFilter{}, 3, 6, 7, "[t1]"},
+		{"past the end", auditFilter{}, 3, 9, 7, "[]"},
+		{"actor", auditFilter{Actor: "bob"}, 0, 0, 2, "[t5 t2]"},
+		{"actor page", auditFilter{Actor: "alice"}, 2, 2, 5, "[t4 t3]"},
+		{"unknown actor", auditFilter{Actor: "carol"}, 0, 0, 0, "[]"},
+		{"dates", auditFilter{From: at(t, "2026-10-02 00:00"), To: at(t, "2026-10-03 00:00")}, 0, 0, 2, "[t3 t2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := queryAudit(tt.filter, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var targets []string
+			for _, e := range entries {
+				targets = append(targets, e.Target)
+			}
+			if got := fmt.Sprint(targets); got != tt.want || total != tt.total {
+				t.Errorf("got %s of %d, want %s of %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handleAuditLog(rec, httptest.NewRequest("GET", "/admin/audit?actor=alice&per_page=2&page=2", nil))
+	if got := rec.Header().Get("X-Total-Count"); rec.Code != 200 || got != "5" {
+		t.Errorf("handler: status %d, X-Total-Count %q, want 200 and 5", rec.Code, got)
+	}
+}
+
+func TestAuditCSV(t *testing.T) {
+	resetDB(t)
+	seedAudit(t, "alice", "bob", "alice", "bob")
+	tests := []struct {
+		name  string
+		query string
+		want  []string // targets, newest first
+	}{
+		{"everything", "", []string{"t4", "t3", "t2", "t1"}},
+		{"actor", "?actor=bob", []string{"t4", "t2"}},
+		{"dates", "?from=2026-10-02&to=2026-10-03", []string{"t3", "t2"}},
+		{"actor and dates", "?actor=alice&from=2026-10-02", []string{"t3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleAuditCSV(rec, httptest.NewRequest("GET", "/admin/audit.csv"+tt.query, nil))
+			records, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(records[0]); got != "[Timestamp Actor Action Target Detail]" {
+				t.Errorf("header %s", got)
+			}
+			var targets []string
+			for _, r := range records[1:] {
+				targets = append(targets, r[3])
+			}
+			if fmt.Sprint(targets) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", targets, tt.want)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handleAuditCSV(rec, httptest.NewRequest("GET", "/admin/audit.csv?from=Oct", nil))
+	if rec.Code != 400 {
+		t.Errorf("bad date: got %d, want 400", rec.Code)
+	}
+}
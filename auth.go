@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/sessions"
+)
+
+// ---------- ADMIN ACCOUNTS ----------
+
+// Account roles. Viewers can open pages and exports but not change data.
+const (
+	roleAdmin  = "admin"
+	roleViewer = "viewer"
+)
+
+const pbkdf2Iter = 100_000
+
+// hashPassword returns "pbkdf2-sha256$iter$salt$key" with hex-encoded salt and key.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iter, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%x$%x", pbkdf2Iter, salt, key), nil
+}
+
+// checkPassword reports whether password matches a hash from hashPassword.
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	salt, err1 := hex.DecodeString(parts[2])
+	want, err2 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// seedAdmin creates the default admin account on first run.
+func seedAdmin() error {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM admins").Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	hash, err := hashPassword(adminPass)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO admins (username, password_hash, role) VALUES (?,?,?)", adminUser, hash, roleAdmin)
+	return err
+}
+
+// authenticate returns the account's role when username/password are valid.
+func authenticate(username, password string) (string, bool) {
+	var hash, role string
+	err := db.QueryRow("SELECT password_hash, role FROM admins WHERE username=?", username).Scan(&hash, &role)
+	if err != nil || !checkPassword(hash, password) {
+		return "", false
+	}
+	return role, true
+}
+
+// sessionRole returns the role stored in the request's session, if any.
+func sessionRole(r *http.Request) string {
+	session, _ := store.Get(r, "session")
+	role, _ := session.Values["role"].(string)
+	return role
+}
+
+// requireAdmin is requireLogin plus a 403 for accounts without the admin role.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireLogin(func(w http.ResponseWriter, r *http.Request) {
+		if sessionRole(r) != roleAdmin {
+			http.Error(w, "Forbidden: admin role required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAdminUserAdd creates an admin or viewer account.
+func handleAdminUserAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	role := r.FormValue("role")
+	if username == "" || password == "" {
+		http.Error(w, "Username and password are required", http.StatusBadRequest)
+		return
+	}
+	if role != roleAdmin && role != roleViewer {
+		http.Error(w, "Role must be admin or viewer", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	_, err = db.Exec("INSERT INTO admins (username, password_hash, role) VALUES (?,?,?)", username, hash, role)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, "Username already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	audit(r, "account.add", username, role)
+	setFlash(w, r, fmt.Sprintf("Created %s account %s.", role, username))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// validatePassword enforces the configured password policy on new and
+// changed passwords.
+func validatePassword(p string) error {
+	if utf8.RuneCountInString(p) < passwordMinLength {
+		return fmt.Errorf("Password must be at least %d characters", passwordMinLength)
+	}
+	var digit, upper, symbol bool
+	for _, c := range p {
+		switch {
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsUpper(c):
+			upper = true
+		case !unicode.IsLetter(c) && !unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	switch {
+	case passwordRequireDigit && !digit:
+		return errors.New("Password must contain a digit")
+	case passwordRequireUpper && !upper:
+		return errors.New("Password must contain an uppercase letter")
+	case passwordRequireSymbol && !symbol:
+		return errors.New("Password must contain a symbol")
+	}
+	return nil
+}
+
+// handleChangePassword lets any signed-in account change its own password
+// after confirming the current one.
+func handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	session, _ := store.Get(r, "session")
+	username, _ := session.Values["username"].(string)
+	if _, ok := authenticate(username, r.FormValue("current")); !ok {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+	password := r.FormValue("new")
+	if err := validatePassword(password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if _, err := db.Exec("UPDATE admins SET password_hash=? WHERE username=?", hash, username); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "account.password", username, "")
+	setFlash(w, r, "Password changed.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ---------- SESSION EXPIRY ----------
+
+// sessionRemaining is how long the signed-in session has left; zero or less
+// means it has expired. Sessions from before expiry was recorded count as
+// expired, so their users simply sign in again.
+func sessionRemaining(session *sessions.Session) time.Duration {
+	expires, ok := session.Values["expires"].(int64)
+	if !ok {
+		return 0
+	}
+	return time.Unix(expires, 0).Sub(appNow())
+}
+
+// signedInSession returns the session if it is signed in and unexpired,
+// otherwise answers 401 and returns nil.
+func signedInSession(w http.ResponseWriter, r *http.Request) *sessions.Session {
+	session, _ := store.Get(r, "session")
+	if auth, _ := session.Values["authenticated"].(bool); !auth || sessionRemaining(session) <= 0 {
+		writeJSONError(w, http.StatusUnauthorized, "Session expired")
+		return nil
+	}
+	return session
+}
+
+// handleSessionTTL reports the seconds left in the caller's session, for
+// pages to warn before it ends.
+func handleSessionTTL(w http.ResponseWriter, r *http.Request) {
+	session := signedInSession(w, r)
+	if session == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Remaining int `json:"remaining"`
+	}{int(sessionRemaining(session) / time.Second)})
+}
+
+// handleSessionKeepalive restarts a still-valid session's full sessionTTL.
+func handleSessionKeepalive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	session := signedInSession(w, r)
+	if session == nil {
+		return
+	}
+	session.Values["expires"] = appNow().Add(sessionTTL).Unix()
+	if err := session.Save(r, w); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Remaining int `json:"remaining"`
+	}{int(sessionTTL / time.Second)})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestViewerCannotMutate(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		method  string
+		path    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"viewer delete", roleViewer, "POST", "/faculty/delete", requireAdmin(handleFacultyDelete), http.StatusForbidden},
+		{"viewer payroll", roleViewer, "GET", "/payroll", requireLogin(handlePayroll), http.StatusOK},
+		{"admin delete", roleAdmin, "POST", "/faculty/delete", requireAdmin(handleFacultyDelete), http.StatusSeeOther},
+		{"signed out delete", "", "POST", "/faculty/delete", requireAdmin(handleFacultyDelete), http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			req := httptest.NewRequest(tt.method, tt.path+"?id="+strconv.Itoa(fid), nil)
+			if tt.role != "" {
+				req = signIn(t, req, tt.role)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if deleted := count(t, "SELECT COUNT(*) FROM faculty") == 0; deleted != (tt.want == http.StatusSeeOther) {
+				t.Errorf("faculty deleted = %v", deleted)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name                 string
+		min                  int
+		digit, upper, symbol bool
+		password             string
+		ok                   bool
+	}{
+		{"long enough", 8, false, false, false, "longenough", true},
+		{"too short", 8, false, false, false, "short", false},
+		{"length counts characters, not bytes", 4, false, false, false, "ñaño", true},
+		{"digit present", 4, true, false, false, "abcd1", true},
+		{"digit missing", 4, true, false, false, "abcde", false},
+		{"upper present", 4, false, true, false, "abcdE", true},
+		{"upper missing", 4, false, true, false, "abcde", false},
+		{"symbol present", 4, false, false, true, "abcd!", true},
+		{"symbol missing", 4, false, false, true, "abcd e", false},
+		{"all rules met", 8, true, true, true, "Str0ng!pass", true},
+		{"all rules, one missing", 8, true, true, true, "Strong!pass", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &passwordMinLength, tt.min)
+			setVar(t, &passwordRequireDigit, tt.digit)
+			setVar(t, &passwordRequireUpper, tt.upper)
+			setVar(t, &passwordRequireSymbol, tt.symbol)
+			if err := validatePassword(tt.password); (err == nil) != tt.ok {
+				t.Errorf("validatePassword(%q) = %v, want ok=%v", tt.password, err, tt.ok)
+			}
+		})
+	}
+}
+
+// withSession signs r in with a session expiring after ttl; a zero ttl
+// leaves the expiry unset, as for sessions from before it was recorded.
+func withSession(t *testing.T, r *http.Request, ttl time.Duration) *http.Request {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	session, _ := store.Get(r, "session")
+	session.Values["authenticated"] = true
+	session.Values["username"] = adminUser
+	session.Values["role"] = "admin"
+	if ttl != 0 {
+		session.Values["expires"] = appNow().Add(ttl).Unix()
+	}
+	if err := session.Save(r, rec); err != nil {
+		t.Fatal(err)
+	}
+	return carryCookies(rec, r)
+}
+
+// remaining reads the seconds a session-ttl or keepalive response reports.
+func remaining(t *testing.T, rec *httptest.ResponseRecorder) int {
+	t.Helper()
+	var got struct {
+		Remaining int `json:"remaining"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("status %d: %v", rec.Code, err)
+	}
+	return got.Remaining
+}
+
+func TestSessionTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		signedIn bool
+		code     int
+		want     int
+	}{
+		{"an hour left", time.Hour, true, http.StatusOK, 3600},
+		{"a minute left", time.Minute, true, http.StatusOK, 60},
+		{"expired", -time.Minute, true, http.StatusUnauthorized, 0},
+		{"no expiry recorded", 0, true, http.StatusUnauthorized, 0},
+		{"signed out", 0, false, http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/session-ttl", nil)
+			if tt.signedIn {
+				req = withSession(t, req, tt.ttl)
+			}
+			rec := httptest.NewRecorder()
+			handleSessionTTL(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d", rec.Code, tt.code)
+			}
+			// a second may tick between signing in and asking
+			if got := remaining(t, rec); tt.code == http.StatusOK && (got > tt.want || got < tt.want-2) {
+				t.Errorf("remaining %d, want about %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionKeepalive(t *testing.T) {
+	setVar(t, &sessionTTL, 30*time.Minute)
+	tests := []struct {
+		name   string
+		method string
+		ttl    time.Duration
+		code   int
+	}{
+		{"extends a valid session", "POST", time.Minute, http.StatusOK},
+		{"expired", "POST", -time.Minute, http.StatusUnauthorized},
+		{"GET", "GET", time.Minute, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withSession(t, httptest.NewRequest(tt.method, "/api/session-keepalive", nil), tt.ttl)
+			rec := httptest.NewRecorder()
+			handleSessionKeepalive(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d", rec.Code, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			if got := remaining(t, rec); got != 1800 {
+				t.Errorf("keepalive reports %d, want 1800", got)
+			}
+			ttlRec := httptest.NewRecorder()
+			handleSessionTTL(ttlRec, carryCookies(rec, httptest.NewRequest("GET", "/api/session-ttl", nil)))
+			if got := remaining(t, ttlRec); got < 1798 {
+				t.Errorf("session has %d seconds left after the keepalive, want about 1800", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// ---------- PUBLIC BOARD ----------
+
+// boardRefreshSeconds is how often the board page reloads itself.
+const boardRefreshSeconds = 30
+
+// handleBoard is a read-only attendance board for shared display screens.
+// It needs no login so kiosks never hold an admin session open; it is only
+// served when PUBLIC_BOARD is enabled, and shows names and scan times only.
+func handleBoard(w http.ResponseWriter, r *http.Request) {
+	if !publicBoard {
+		http.NotFound(w, r)
+		return
+	}
+	recent, err := recentScans(20)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	// the board only shows today's scans, so times need no date
+	today := dayStart(appNow())
+	var events []scanEvent
+	for _, e := range recent {
+		if !e.at.Before(today) {
+			events = append(events, e)
+		}
+	}
+	rows, err := dayReport(appNow())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	present := 0
+	for _, row := range rows {
+		if row.Present {
+			present++
+		}
+	}
+	week, err := weekHours(appNow())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	tplBoard.Execute(w, struct {
+		branding
+		Today   string
+		Refresh int
+		Present int
+		Total   int
+		Week    float64
+		Events  []scanEvent
+	}{
+		branding: pageBranding(),
+		Today:    appNow().Format("Monday, January 2, 2006"),
+		Refresh:  boardRefreshSeconds,
+		Present:  present,
+		Total:    len(rows),
+		Week:     week,
+		Events:   events,
+	})
+}
+
+// weekHours totals everyone's hours from Monday through today. It goes
+// through computePayroll so the board shows what a payroll run over the same
+// days would, rounding included.
+func weekHours(now time.Time) (float64, error) {
+	today := dayStart(now)
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	rows, _, err := computePayroll(monday, today.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, row := range rows {
+		total += row.TotalHours
+	}
+	return total, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicBoard(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 150)
+	today := appNow().Format("2006-01-02")
+	addSession(t, fid, today+" 00:01", "")
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    int
+	}{
+		{"enabled", true, http.StatusOK},
+		{"disabled", false, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &publicBoard, tt.enabled)
+			rec := httptest.NewRecorder()
+			// no session cookie: kiosks never sign in
+			handleBoard(rec, httptest.NewRequest("GET", "/board", nil))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+			if !tt.enabled {
+				return
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "Ana Cruz") {
+				t.Error("today's scan is not on the board")
+			}
+			if strings.Contains(body, "150") || strings.Contains(body, "/faculty/delete") {
+				t.Error("the board shows admin-only details")
+			}
+		})
+	}
+}
+
+func TestWeekHoursMatchesPayroll(t *testing.T) {
+	tests := []struct {
+		name      string
+		increment int
+		dir       string
+		want      float64
+	}{
+		// Ana 4:07 + 2:20 = 6:27, Ben 1:52
+		{"quarter hours nearest", 15, "nearest", 6.5 + 1.75},
+		{"quarter hours up", 15, "up", 6.5 + 2},
+		{"tenths down", 6, "down", 6.4 + 1.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &roundIncrement, tt.increment)
+			setVar(t, &roundDir, tt.dir)
+			ana, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			ben, _ := addFaculty(t, "Ben Reyes", "Teacher", 100)
+			addSession(t, ana, "2026-10-12 08:00", "2026-10-12 12:07") // Monday
+			addSession(t, ana, "2026-10-14 13:00", "2026-10-14 15:20")
+			addSession(t, ben, "2026-10-13 09:00", "2026-10-13 10:52")
+			// the Sunday before and the day after stay out
+			addSession(t, ana, "2026-10-11 08:00", "2026-10-11 12:00")
+			addSession(t, ben, "2026-10-15 08:00", "2026-10-15 12:00")
+
+			now := at(t, "2026-10-14 18:00") // Wednesday
+			got, err := weekHours(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, _, err := computePayroll(at(t, "2026-10-12 00:00"), at(t, "2026-10-15 00:00"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			payroll := 0.0
+			for _, row := range rows {
+				payroll += row.TotalHours
+			}
+			if formatDuration(got) != formatDuration(payroll) || formatDuration(got) != formatDuration(tt.want) {
+				t.Errorf("board %s, payroll %s, want %s", formatDuration(got), formatDuration(payroll), formatDuration(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ---------- BREAKS ----------
+
+// scanTypes are the accepted values of the scan "type" parameter. A blank
+// type is the usual scan that toggles clock IN and OUT; the break types are
+// for kiosks with a separate break button and need an open session.
+var scanTypes = map[string]bool{
+	"":            true,
+	"break-start": true,
+	"break-end":   true,
+}
+
+// Refusals of a break scan, shown to the faculty on the scan page.
+var (
+	errNotClockedIn = errors.New("You are not clocked in. Clock in before starting a break.")
+	errOnBreak      = errors.New("You are already on a break.")
+	errNotOnBreak   = errors.New("You are not on a break.")
+)
+
+// breakSpan is one break taken within a session; End is invalid while the
+// break is still running.
+type breakSpan struct {
+	Start time.Time
+	End   sql.NullTime
+}
+
+// minutesOnBreak is the whole minutes of the session's breaks that fall within
+// [in, out]. A break never ended runs until out, so a faculty who clocks out
+// straight from a break is not paid for it.
+func (s session) minutesOnBreak(in, out time.Time) int {
+	var total time.Duration
+	for _, b := range s.Breaks {
+		start, end := b.Start, out
+		if b.End.Valid && b.End.Time.Before(out) {
+			end = b.End.Time
+		}
+		if start.Before(in) {
+			start = in
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return int(total / time.Minute)
+}
+
+// loadBreaks attaches each session's breaks to it.
+func loadBreaks(sessions []session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	idx := map[int]int{}
+	ids := make([]any, len(sessions))
+	for i, s := range sessions {
+		idx[s.ID] = i
+		ids[i] = s.ID
+	}
+	rs, err := db.Query("SELECT dtr_id, start_time, end_time FROM breaks WHERE dtr_id IN (?"+
+		strings.Repeat(",?", len(ids)-1)+") ORDER BY start_time", ids...)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var id int
+		var b breakSpan
+		if err := rs.Scan(&id, &b.Start, &b.End); err != nil {
+			return err
+		}
+		if i, ok := idx[id]; ok {
+			sessions[i].Breaks = append(sessions[i].Breaks, b)
+		}
+	}
+	return rs.Err()
+}
+
+// recordBreak starts or ends a break on the faculty's open session and
+// returns the status to show on the scan page. Scans that don't fit the
+// faculty's state are refused with one of the errors above.
+func recordBreak(fid int, typ string, now time.Time) (string, error) {
+	var dtrID int
+	err := db.QueryRow("SELECT id FROM dtr WHERE faculty_id=? AND out_time IS NULL ORDER BY in_time DESC LIMIT 1", fid).Scan(&dtrID)
+	if err == sql.ErrNoRows {
+		return "", errNotClockedIn
+	}
+	if err != nil {
+		return "", err
+	}
+	var breakID int
+	err = db.QueryRow("SELECT id FROM breaks WHERE dtr_id=? AND end_time IS NULL", dtrID).Scan(&breakID)
+	open := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if typ == "break-start" {
+		if open {
+			return "", errOnBreak
+		}
+		if _, err := db.Exec("INSERT INTO breaks(dtr_id,start_time) VALUES (?,?)", dtrID, now); err != nil {
+			return "", err
+		}
+		return "Break START", nil
+	}
+	if !open {
+		return "", errNotOnBreak
+	}
+	if _, err := db.Exec("UPDATE breaks SET end_time=? WHERE id=?", now, breakID); err != nil {
+		return "", err
+	}
+	return "Break END", nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestBreakScans(t *testing.T) {
+	resetDB(t)
+	setVar(t, &scanCooldown, 0)
+	fid, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	scan := func(typ string) int {
+		form := url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}}
+		if typ != "" {
+			form.Set("type", typ)
+		}
+		return postScan(t, form).Code
+	}
+
+	steps := []struct {
+		name string
+		typ  string
+		want int
+	}{
+		{"break before clocking in", "break-start", http.StatusConflict},
+		{"clock in", "", http.StatusOK},
+		{"break end without a break", "break-end", http.StatusConflict},
+		{"break start", "break-start", http.StatusOK},
+		{"second break start", "break-start", http.StatusConflict},
+		{"break end", "break-end", http.StatusOK},
+		{"unknown type", "lunch", http.StatusBadRequest},
+		{"clock out", "", http.StatusOK},
+	}
+	for _, s := range steps {
+		if got := scan(s.typ); got != s.want {
+			t.Fatalf("%s: got %d, want %d", s.name, got, s.want)
+		}
+	}
+	if n := count(t, "SELECT COUNT(*) FROM breaks WHERE end_time IS NOT NULL"); n != 1 {
+		t.Fatalf("%d closed breaks recorded, want 1", n)
+	}
+
+	// move the recorded scans to known times to check the paid hours
+	if _, err := db.Exec("UPDATE dtr SET in_time=?, out_time=? WHERE faculty_id=?",
+		at(t, "2026-10-14 08:00"), at(t, "2026-10-14 17:00"), fid); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		hours      float64
+	}{
+		{"hour lunch", "2026-10-14 12:00", "2026-10-14 13:00", 8},
+		{"short break", "2026-10-14 10:00", "2026-10-14 10:15", 8.75},
+		{"break past clock-out counts to clock-out", "2026-10-14 16:30", "2026-10-14 18:00", 8.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.Exec("UPDATE breaks SET start_time=?, end_time=?", at(t, tt.start), at(t, tt.end)); err != nil {
+				t.Fatal(err)
+			}
+			row := payrollByID(t, "2026-10-14", "2026-10-15")[fid]
+			if row.TotalHours != tt.hours {
+				t.Errorf("got %.2f hours, want %.2f", row.TotalHours, tt.hours)
+			}
+		})
+	}
+}
@@ -0,0 +1,782 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"image/color"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // so APP_TZ works on hosts without a zoneinfo database
+	"unicode/utf8"
+)
+
+// ---------- CONFIG ----------
+
+// scanAllowlist restricts /scan/ to these networks (SCAN_ALLOW_CIDRS,
+// comma-separated). Empty allows every client.
+var scanAllowlist []*net.IPNet
+
+// templateDir, when set (TEMPLATE_DIR), is searched for templates before the
+// embedded copies so deployments can customize the HTML without rebuilding.
+var templateDir string
+
+// hoursFormat selects how hours are displayed in HTML views (HOURS_FORMAT):
+// "decimal" (7.75, default) or "hhmm" (7:45). CSV exports always use decimals.
+var hoursFormat = "decimal"
+
+// overtimeDailyHours is the default daily hours before overtime
+// (OVERTIME_DAILY_HOURS); overtimeRoleHours overrides it per role
+// (OVERTIME_ROLE_HOURS, e.g. "Faculty=6,Staff=8").
+var overtimeDailyHours = 8.0
+var overtimeRoleHours = map[string]float64{}
+
+// minRestHours is the least rest labor rules allow between one session's
+// clock-out and the next clock-in (MIN_REST_HOURS, default 8); the
+// short-rest report flags anything less.
+var minRestHours = 8.0
+
+// allowedRoles, when set (ALLOWED_ROLES, e.g. "Faculty,Staff,Admin"), is the
+// only roles faculty may be added with; unset, any role is accepted.
+var allowedRoles []string
+
+// roleDefaultRates is the rate a faculty added with a blank rate gets for
+// their role (ROLE_DEFAULT_RATES, e.g. "Instructor=120,Staff=90"); roles
+// without one need the rate typed in.
+var roleDefaultRates = map[string]float64{}
+
+// baseURL is the scheme and host printed in QR payloads (BASE_URL, e.g.
+// "https://dtr.slac.edu.ph"). Empty uses the host of the admin's request.
+var baseURL string
+
+// logoPath is the logo image used on page headers and PDFs (LOGO_PATH);
+// logoHeightMM is its printed height on QR cards and timesheets (LOGO_HEIGHT_MM).
+var logoPath = "img/slac_logo.png"
+var logoHeightMM = 10.0
+
+// SMTP settings for outgoing mail (SMTP_HOST, SMTP_PORT, SMTP_USER,
+// SMTP_PASS, SMTP_FROM). Mail is disabled while SMTP_HOST is empty.
+var smtpHost, smtpPort, smtpUser, smtpPass, smtpFrom string
+
+// summaryRecipients receive the daily attendance summary (SUMMARY_RECIPIENTS,
+// comma-separated) at summaryHour:summaryMinute (SUMMARY_TIME, default 18:00).
+var summaryRecipients []string
+var summaryHour, summaryMinute = 18, 0
+
+// sessionTTL is how long a sign-in lasts unless kept alive
+// (SESSION_TTL_MINUTES, default 60). Pages warn sessionWarn before it ends
+// (SESSION_WARN_SECONDS, default 120) and offer to extend it.
+var sessionTTL = time.Hour
+
+// sessionStore is where sign-ins are kept (SESSION_STORE): "cookie" (default)
+// keeps them in the signed cookie; "file" (under sessionDir, SESSION_DIR,
+// default data/sessions) and "sqlite" keep them on the server, so logging out
+// or an admin can revoke them for good.
+var sessionStore = "cookie"
+var sessionDir = "data/sessions"
+var sessionWarn = 2 * time.Minute
+
+// maxPDFRenders caps how many PDFs are rendered at once (MAX_PDF_RENDERS,
+// default 2); further requests queue briefly, then get a 503.
+var maxPDFRenders = 2
+
+// maxQRCards caps the cards in one QR card PDF (MAX_QR_CARDS, default 300);
+// larger rosters are printed in batches.
+var maxQRCards = 300
+
+// sqliteBusyTimeout is how long (ms) a connection waits on a locked database
+// (SQLITE_BUSY_TIMEOUT_MS); raise it on slow disks.
+var sqliteBusyTimeout = 5000
+
+// maxUploadBytes caps the request body of file uploads such as CSV imports
+// (MAX_UPLOAD_MB, default 5).
+var maxUploadBytes int64 = 5 << 20
+
+// production is set when ENV=production; it turns on Secure session cookies
+// unless COOKIE_SECURE says otherwise, so local HTTP dev keeps working.
+var production bool
+
+// cookieSecure and cookieSameSite are applied to the session cookie
+// (COOKIE_SECURE=true|false, COOKIE_SAMESITE=lax|strict, default lax).
+var cookieSecure bool
+var cookieSameSite = http.SameSiteLaxMode
+
+// appTitle and schoolName brand every page (APP_TITLE, SCHOOL_NAME).
+var appTitle = "DTR & Payroll"
+var schoolName = "St. Louis Anne Colleges"
+
+// dayModel picks how a day's worked minutes are counted (DAY_MODEL):
+// "sum-sessions" adds up each clock-in/out pair (default), "span" takes first
+// in to last out minus breakMinutes (BREAK_MINUTES, default 0) or the day's
+// recorded break scans, whichever is longer.
+var dayModel = "sum-sessions"
+var breakMinutes = 0
+
+// dayRateMinHours is the least a day must total to count as a worked day
+// for faculty paid per day (DAY_RATE_MIN_HOURS, default 4).
+var dayRateMinHours = 4.0
+
+// negativeSessionLimit is how many out-before-in sessions a faculty may have
+// in a payroll period before their row is flagged (NEGATIVE_SESSION_LIMIT,
+// default 0: any such session flags the row).
+var negativeSessionLimit = 0
+
+// roundIncrement is the minutes that reported and paid time is rounded to
+// (ROUND_INCREMENT_MINUTES, default 15); roundDir is "nearest" (default),
+// "up" or "down" (ROUND_DIR).
+var roundIncrement = 15
+var roundDir = "nearest"
+
+// roundAt is when rounding happens (ROUND_AT): "payroll" (default) keeps the
+// raw scan times and rounds totals when they are reported; "scan" stores each
+// scan time already rounded to roundIncrement in roundDir.
+var roundAt = "payroll"
+
+// mergeGap is how close a clock-in may follow the previous clock-out for the
+// two sessions to count as one scanner misfire (MERGE_GAP_SECONDS, default
+// 30). Payroll merges them when mergeAtPayroll is set (MERGE_AT_PAYROLL=true);
+// the admin merge cleanup rewrites the stored rows.
+var mergeGap = 30 * time.Second
+var mergeAtPayroll bool
+
+// roundMinTotal exempts tiny period totals from rounding (ROUND_MIN_TOTAL_MINUTES,
+// default 0, off): a faculty's total under it counts as zero, so e.g. 10 stops
+// a stray 6-minute session being rounded up to a paid quarter hour.
+var roundMinTotal = 0
+
+// publicBoard serves the read-only /board display without a login
+// (PUBLIC_BOARD=true); it is off by default.
+var publicBoard bool
+
+// capToShiftEnd pays work only up to each faculty's scheduled shift end
+// unless the session's overtime was approved (CAP_AT_SHIFT_END=true). Faculty
+// without a shift end are never capped.
+var capToShiftEnd bool
+
+// logUnknownScans logs every scan of an unregistered token with the client
+// address (LOG_UNKNOWN_SCANS=true), for spotting forged or probing cards.
+var logUnknownScans bool
+
+// appLoc is the deployment's time zone (APP_TZ, then TZ, default
+// Asia/Manila). Days, pay periods and stored times all use it rather than the
+// process-wide time.Local.
+var appLoc *time.Location
+
+// appNow returns the current time in appLoc.
+func appNow() time.Time {
+	return time.Now().In(appLoc)
+}
+
+// moneyRound is how final pay is shown in payroll views and exports
+// (MONEY_ROUND): "centavo" (default) or "peso" for cash payrolls.
+var moneyRound = "centavo"
+
+// retentionDays is how long dtr records are kept before the admin purge may
+// delete them (DTR_RETENTION_DAYS, default 730, about two years).
+var retentionDays = 730
+
+// maxImportRows caps the rows of one CSV import (MAX_IMPORT_ROWS,
+// default 1000); larger files are refused before anything is inserted.
+var maxImportRows = 1000
+
+// importDecimal is the decimal separator of numbers in CSV imports
+// (IMPORT_DECIMAL_SEPARATOR): "." (default) or "," for locales that write
+// "12,50". Comma decimals must be quoted in a comma-separated file.
+var importDecimal = "."
+
+// scanRedirectURL, when set (SCAN_REDIRECT_URL), sends the scan result page
+// back to a kiosk home after scanRedirectSeconds (SCAN_REDIRECT_SECONDS,
+// default 5). scanMessage (SCAN_MESSAGE) replaces the success message; it is
+// an html/template over .Name, .Role, .Status, .Time and .Elapsed (the
+// rounded session hours on clock-out, else empty), e.g.
+// "Thank you, {{.Name}}! {{.Status}} at {{.Time}}".
+var scanRedirectURL string
+var scanRedirectSeconds = 5
+var scanMessage *template.Template
+
+// alreadyInMessage is shown when a clock-in loses a race with another scan
+// that already opened the faculty's session (ALREADY_IN_MESSAGE); it is an
+// html/template over .Name, .Role and .Since, the open session's HH:MM.
+var alreadyInMessage = template.Must(template.New("already-in").Parse("You are already clocked in since {{.Since}}."))
+
+// scanCooldown is the least time between two clock scans of one faculty
+// (SCAN_COOLDOWN_SECONDS, default 60, 0 disables it), so a card read twice
+// doesn't clock in and straight back out. A faculty's own scan cooldown, set
+// with their schedule, overrides it. deviceCooldowns lengthens either per
+// kiosk device_id (SCAN_COOLDOWN_DEVICES, e.g. "gate=120,library=90"); since
+// kiosks report their own id, a device value below the minimum is ignored.
+var scanCooldown = 60 * time.Second
+var deviceCooldowns = map[string]time.Duration{}
+
+// maxDailyHours refuses a clock-in once a faculty has worked this many hours
+// that day (MAX_DAILY_HOURS, default 0, off), so a runaway session can't be
+// followed by more. A faculty's own cap, set with their schedule, overrides it.
+var maxDailyHours float64
+
+// csvDelimiter and csvBOM shape the payroll CSV for spreadsheet locales
+// (CSV_DELIMITER, a single character or "tab", default ","; CSV_BOM=true
+// prepends a UTF-8 byte order mark so Excel shows accents correctly).
+var csvDelimiter = ','
+var csvBOM bool
+
+// Password policy for new accounts and password changes
+// (PASSWORD_MIN_LENGTH, default 8; PASSWORD_REQUIRE_DIGIT,
+// PASSWORD_REQUIRE_UPPER, PASSWORD_REQUIRE_SYMBOL, default false).
+var passwordMinLength = 8
+var passwordRequireDigit, passwordRequireUpper, passwordRequireSymbol bool
+
+// qrForeground and qrBackground color generated QR codes (QR_FOREGROUND,
+// QR_BACKGROUND as #RRGGBB, default black on white); pairs without enough
+// contrast for scanners are rejected. qrQuietZone=false (QR_QUIET_ZONE)
+// drops the blank border, for cards that already leave a white margin.
+var qrForeground color.Color = color.Black
+var qrBackground color.Color = color.White
+var qrQuietZone = true
+
+// minQRContrast is the lowest foreground/background contrast ratio accepted
+// for QR colors (the WCAG AA ratio), with the foreground the darker of the two.
+const minQRContrast = 4.5
+
+// loadConfig reads deployment settings from the environment.
+func loadConfig() error {
+	tz := envOr("APP_TZ", envOr("TZ", "Asia/Manila"))
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fmt.Errorf("APP_TZ: %w", err)
+	}
+	appLoc = loc
+
+	nets, err := parseCIDRs(os.Getenv("SCAN_ALLOW_CIDRS"))
+	if err != nil {
+		return fmt.Errorf("SCAN_ALLOW_CIDRS: %w", err)
+	}
+	scanAllowlist = nets
+	templateDir = os.Getenv("TEMPLATE_DIR")
+	appTitle = envOr("APP_TITLE", appTitle)
+	schoolName = envOr("SCHOOL_NAME", schoolName)
+	baseURL = strings.TrimRight(os.Getenv("BASE_URL"), "/")
+	if v := os.Getenv("LOGO_PATH"); v != "" {
+		logoPath = v
+	}
+	if v := os.Getenv("LOGO_HEIGHT_MM"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			return fmt.Errorf("LOGO_HEIGHT_MM: invalid height %q", v)
+		}
+		logoHeightMM = h
+	}
+
+	switch f := os.Getenv("HOURS_FORMAT"); f {
+	case "":
+	case "decimal", "hhmm":
+		hoursFormat = f
+	default:
+		return fmt.Errorf("HOURS_FORMAT: unknown format %q (want decimal or hhmm)", f)
+	}
+
+	if v := os.Getenv("OVERTIME_DAILY_HOURS"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			return fmt.Errorf("OVERTIME_DAILY_HOURS: invalid hours %q", v)
+		}
+		overtimeDailyHours = h
+	}
+	switch m := os.Getenv("DAY_MODEL"); m {
+	case "":
+	case "sum-sessions", "span":
+		dayModel = m
+	default:
+		return fmt.Errorf("DAY_MODEL: unknown model %q (want sum-sessions or span)", m)
+	}
+	if v := os.Getenv("BREAK_MINUTES"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 0 {
+			return fmt.Errorf("BREAK_MINUTES: invalid minutes %q", v)
+		}
+		breakMinutes = m
+	}
+	if v := os.Getenv("DAY_RATE_MIN_HOURS"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h < 0 || h > 24 {
+			return fmt.Errorf("DAY_RATE_MIN_HOURS: invalid hours %q", v)
+		}
+		dayRateMinHours = h
+	}
+	if v := os.Getenv("NEGATIVE_SESSION_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("NEGATIVE_SESSION_LIMIT: invalid count %q", v)
+		}
+		negativeSessionLimit = n
+	}
+	switch m := os.Getenv("MONEY_ROUND"); m {
+	case "":
+	case "centavo", "peso":
+		moneyRound = m
+	default:
+		return fmt.Errorf("MONEY_ROUND: unknown mode %q (want centavo or peso)", m)
+	}
+	if v := os.Getenv("ROUND_INCREMENT_MINUTES"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil || m <= 0 {
+			return fmt.Errorf("ROUND_INCREMENT_MINUTES: invalid minutes %q", v)
+		}
+		roundIncrement = m
+	}
+	switch v := os.Getenv("ROUND_AT"); v {
+	case "":
+	case "scan", "payroll":
+		roundAt = v
+	default:
+		return fmt.Errorf("ROUND_AT: unknown value %q (want scan or payroll)", v)
+	}
+	if v := os.Getenv("MERGE_GAP_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("MERGE_GAP_SECONDS: invalid seconds %q", v)
+		}
+		mergeGap = time.Duration(n) * time.Second
+	}
+	if v := os.Getenv("MERGE_AT_PAYROLL"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("MERGE_AT_PAYROLL: invalid value %q", v)
+		}
+		mergeAtPayroll = b
+	}
+	if v := os.Getenv("ROUND_MIN_TOTAL_MINUTES"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 0 {
+			return fmt.Errorf("ROUND_MIN_TOTAL_MINUTES: invalid minutes %q", v)
+		}
+		roundMinTotal = m
+	}
+	switch d := os.Getenv("ROUND_DIR"); d {
+	case "":
+	case "nearest", "up", "down":
+		roundDir = d
+	default:
+		return fmt.Errorf("ROUND_DIR: unknown direction %q (want nearest, up or down)", d)
+	}
+	if v := os.Getenv("SQLITE_BUSY_TIMEOUT_MS"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			return fmt.Errorf("SQLITE_BUSY_TIMEOUT_MS: invalid value %q", v)
+		}
+		sqliteBusyTimeout = ms
+	}
+	if v := os.Getenv("DTR_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("DTR_RETENTION_DAYS: invalid days %q", v)
+		}
+		retentionDays = n
+	}
+	if v := os.Getenv("SESSION_TTL_MINUTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("SESSION_TTL_MINUTES: invalid minutes %q", v)
+		}
+		sessionTTL = time.Duration(n) * time.Minute
+	}
+	if v := os.Getenv("SESSION_STORE"); v != "" {
+		if !sessionStores[v] {
+			return fmt.Errorf("SESSION_STORE: unknown store %q (want cookie, file or sqlite)", v)
+		}
+		sessionStore = v
+	}
+	if v := os.Getenv("SESSION_DIR"); v != "" {
+		sessionDir = v
+	}
+	if v := os.Getenv("SESSION_WARN_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("SESSION_WARN_SECONDS: invalid seconds %q", v)
+		}
+		sessionWarn = time.Duration(n) * time.Second
+	}
+	if v := os.Getenv("MAX_PDF_RENDERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("MAX_PDF_RENDERS: invalid count %q", v)
+		}
+		maxPDFRenders = n
+	}
+	if v := os.Getenv("MAX_QR_CARDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("MAX_QR_CARDS: invalid count %q", v)
+		}
+		maxQRCards = n
+	}
+	if v := os.Getenv("MAX_IMPORT_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("MAX_IMPORT_ROWS: invalid count %q", v)
+		}
+		maxImportRows = n
+	}
+	switch v := os.Getenv("IMPORT_DECIMAL_SEPARATOR"); v {
+	case "":
+	case ".", ",":
+		importDecimal = v
+	default:
+		return fmt.Errorf("IMPORT_DECIMAL_SEPARATOR: invalid separator %q (want . or ,)", v)
+	}
+	if v := os.Getenv("MAX_UPLOAD_MB"); v != "" {
+		mb, err := strconv.Atoi(v)
+		if err != nil || mb <= 0 {
+			return fmt.Errorf("MAX_UPLOAD_MB: invalid size %q", v)
+		}
+		maxUploadBytes = int64(mb) << 20
+	}
+	if v := os.Getenv("PUBLIC_BOARD"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("PUBLIC_BOARD: invalid value %q", v)
+		}
+		publicBoard = b
+	}
+	if v := os.Getenv("CAP_AT_SHIFT_END"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("CAP_AT_SHIFT_END: invalid value %q", v)
+		}
+		capToShiftEnd = b
+	}
+	if v := os.Getenv("LOG_UNKNOWN_SCANS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("LOG_UNKNOWN_SCANS: invalid value %q", v)
+		}
+		logUnknownScans = b
+	}
+	scanRedirectURL = os.Getenv("SCAN_REDIRECT_URL")
+	if v := os.Getenv("SCAN_REDIRECT_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("SCAN_REDIRECT_SECONDS: invalid seconds %q", v)
+		}
+		scanRedirectSeconds = n
+	}
+	if v := os.Getenv("SCAN_MESSAGE"); v != "" {
+		t, err := template.New("scan-message").Parse(v)
+		if err != nil {
+			return fmt.Errorf("SCAN_MESSAGE: %w", err)
+		}
+		scanMessage = t
+	}
+	if v := os.Getenv("ALREADY_IN_MESSAGE"); v != "" {
+		t, err := template.New("already-in").Parse(v)
+		if err != nil {
+			return fmt.Errorf("ALREADY_IN_MESSAGE: %w", err)
+		}
+		alreadyInMessage = t
+	}
+	if v := os.Getenv("SCAN_COOLDOWN_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("SCAN_COOLDOWN_SECONDS: invalid seconds %q", v)
+		}
+		scanCooldown = time.Duration(n) * time.Second
+	}
+	if v := os.Getenv("MAX_DAILY_HOURS"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h < 0 {
+			return fmt.Errorf("MAX_DAILY_HOURS: invalid hours %q", v)
+		}
+		maxDailyHours = h
+	}
+	devCooldowns, err := parseDeviceCooldowns(os.Getenv("SCAN_COOLDOWN_DEVICES"))
+	if err != nil {
+		return fmt.Errorf("SCAN_COOLDOWN_DEVICES: %w", err)
+	}
+	deviceCooldowns = devCooldowns
+	if v := os.Getenv("CSV_DELIMITER"); v != "" {
+		d, err := parseDelimiter(v)
+		if err != nil {
+			return fmt.Errorf("CSV_DELIMITER: %w", err)
+		}
+		csvDelimiter = d
+	}
+	if v := os.Getenv("CSV_BOM"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("CSV_BOM: invalid value %q", v)
+		}
+		csvBOM = b
+	}
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("PASSWORD_MIN_LENGTH: invalid length %q", v)
+		}
+		passwordMinLength = n
+	}
+	for key, dst := range map[string]*bool{
+		"PASSWORD_REQUIRE_DIGIT":  &passwordRequireDigit,
+		"PASSWORD_REQUIRE_UPPER":  &passwordRequireUpper,
+		"PASSWORD_REQUIRE_SYMBOL": &passwordRequireSymbol,
+	} {
+		if v := os.Getenv(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid value %q", key, v)
+			}
+			*dst = b
+		}
+	}
+	if err := loadQRColors(); err != nil {
+		return err
+	}
+	production = os.Getenv("ENV") == "production"
+	cookieSecure = production
+	if v := os.Getenv("COOKIE_SECURE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("COOKIE_SECURE: invalid value %q", v)
+		}
+		cookieSecure = b
+	}
+	switch v := strings.ToLower(os.Getenv("COOKIE_SAMESITE")); v {
+	case "", "lax":
+		cookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		cookieSameSite = http.SameSiteStrictMode
+	default:
+		return fmt.Errorf("COOKIE_SAMESITE: unknown mode %q (want lax or strict)", v)
+	}
+	smtpHost = os.Getenv("SMTP_HOST")
+	smtpPort = envOr("SMTP_PORT", "587")
+	smtpUser = os.Getenv("SMTP_USER")
+	smtpPass = os.Getenv("SMTP_PASS")
+	smtpFrom = os.Getenv("SMTP_FROM")
+	summaryRecipients = splitList(os.Getenv("SUMMARY_RECIPIENTS"))
+	if v := os.Getenv("SUMMARY_TIME"); v != "" {
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			return fmt.Errorf("SUMMARY_TIME: invalid time %q, expected HH:MM", v)
+		}
+		summaryHour, summaryMinute = t.Hour(), t.Minute()
+	}
+	roleHours, err := parseRoleValues(os.Getenv("OVERTIME_ROLE_HOURS"))
+	if err != nil {
+		return fmt.Errorf("OVERTIME_ROLE_HOURS: %w", err)
+	}
+	overtimeRoleHours = roleHours
+	if v := os.Getenv("MIN_REST_HOURS"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			return fmt.Errorf("MIN_REST_HOURS: invalid hours %q", v)
+		}
+		minRestHours = h
+	}
+	allowedRoles = splitList(os.Getenv("ALLOWED_ROLES"))
+	roleRates, err := parseRoleValues(os.Getenv("ROLE_DEFAULT_RATES"))
+	if err != nil {
+		return fmt.Errorf("ROLE_DEFAULT_RATES: %w", err)
+	}
+	roleDefaultRates = roleRates
+	return nil
+}
+
+// loadQRColors reads QR_FOREGROUND, QR_BACKGROUND and QR_QUIET_ZONE.
+func loadQRColors() error {
+	fg, bg := qrForeground, qrBackground
+	var err error
+	if v := os.Getenv("QR_FOREGROUND"); v != "" {
+		if fg, err = parseHexColor(v); err != nil {
+			return fmt.Errorf("QR_FOREGROUND: %w", err)
+		}
+	}
+	if v := os.Getenv("QR_BACKGROUND"); v != "" {
+		if bg, err = parseHexColor(v); err != nil {
+			return fmt.Errorf("QR_BACKGROUND: %w", err)
+		}
+	}
+	if err := checkQRContrast(fg, bg); err != nil {
+		return err
+	}
+	qrForeground, qrBackground = fg, bg
+	if v := os.Getenv("QR_QUIET_ZONE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("QR_QUIET_ZONE: invalid value %q", v)
+		}
+		qrQuietZone = b
+	}
+	return nil
+}
+
+// parseHexColor parses "#RRGGBB" (the # is optional).
+func parseHexColor(s string) (color.Color, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return nil, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// checkQRContrast rejects color pairs scanners may not read: the foreground
+// must be darker than the background by at least minQRContrast.
+func checkQRContrast(fg, bg color.Color) error {
+	lf, lb := luminance(fg), luminance(bg)
+	if lf >= lb {
+		return fmt.Errorf("QR foreground must be darker than the background")
+	}
+	if ratio := (lb + 0.05) / (lf + 0.05); ratio < minQRContrast {
+		return fmt.Errorf("QR colors have contrast %.1f:1, need at least %.1f:1", ratio, minQRContrast)
+	}
+	return nil
+}
+
+// luminance is the WCAG relative luminance of c.
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	lin := func(v uint32) float64 {
+		s := float64(v) / 0xffff
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(r) + 0.7152*lin(g) + 0.0722*lin(b)
+}
+
+// parseDelimiter accepts a single delimiter character or "tab".
+func parseDelimiter(s string) (rune, error) {
+	if strings.EqualFold(s, "tab") {
+		return '\t', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r[0], nil
+}
+
+// envOr returns the environment variable or def when it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// splitList splits a comma-separated list, dropping blanks.
+func splitList(list string) []string {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parseRoleValues parses "Role=value,Role=value" of positive numbers keyed by
+// normalizeRole.
+func parseRoleValues(list string) (map[string]float64, error) {
+	m := map[string]float64{}
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		role, v, ok := strings.Cut(pair, "=")
+		h, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if !ok || err != nil || h <= 0 {
+			return nil, fmt.Errorf("invalid entry %q", pair)
+		}
+		m[normalizeRole(role)] = h
+	}
+	return m, nil
+}
+
+// parseDeviceCooldowns parses "device=seconds" pairs separated by commas.
+func parseDeviceCooldowns(list string) (map[string]time.Duration, error) {
+	m := map[string]time.Duration{}
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		device, v, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if !ok || err != nil || n < 0 || strings.TrimSpace(device) == "" {
+			return nil, fmt.Errorf("invalid entry %q", pair)
+		}
+		m[strings.TrimSpace(device)] = time.Duration(n) * time.Second
+	}
+	return m, nil
+}
+
+// checkRole returns role in the spelling allowedRoles gives it, or an error
+// if the allowlist is set and role isn't on it.
+func checkRole(role string) (string, error) {
+	if len(allowedRoles) == 0 {
+		return role, nil
+	}
+	for _, a := range allowedRoles {
+		if normalizeRole(a) == normalizeRole(role) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("role %q is not allowed (want one of %s)", strings.TrimSpace(role), strings.Join(allowedRoles, ", "))
+}
+
+// normalizeRole folds case and surrounding space so "Teacher" and " teacher" match.
+func normalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
+
+// parseCIDRs parses a comma-separated list of CIDRs; bare IPs are taken as single hosts.
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if ip := net.ParseIP(s); ip != nil {
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// clientAllowed reports whether the request's remote address is in the allowlist.
+func clientAllowed(remoteAddr string, allow []*net.IPNet) bool {
+	if len(allow) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- MANUAL DTR EDITS ----------
+
+// parseDateTime parses an HTML datetime-local value in local time.
+func parseDateTime(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02T15:04", s, appLoc)
+}
+
+// handleDTRSave inserts a dtr record, or updates one when id is given.
+// Changes touching a locked pay period are rejected.
+func handleDTRSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	in, err := parseDateTime(r.FormValue("in"))
+	if err != nil {
+		http.Error(w, "Invalid in time, expected YYYY-MM-DDTHH:MM", http.StatusBadRequest)
+		return
+	}
+	var out sql.NullTime
+	if s := r.FormValue("out"); s != "" {
+		t, err := parseDateTime(s)
+		if err != nil {
+			http.Error(w, "Invalid out time, expected YYYY-MM-DDTHH:MM", http.StatusBadRequest)
+			return
+		}
+		out = sql.NullTime{Time: t, Valid: true}
+	}
+	warnings, err := validateSession(in, out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := checkUnlocked(sql.NullTime{Time: in, Valid: true}, out); err != nil {
+		writeLockError(w, err)
+		return
+	}
+
+	if id := r.FormValue("id"); id != "" {
+		// the record's current dates must be unlocked too, or it could be moved out of a locked period
+		var oldIn, oldOut sql.NullTime
+		if err := db.QueryRow("SELECT in_time, out_time FROM dtr WHERE id=?", id).Scan(&oldIn, &oldOut); err != nil {
+			http.Error(w, "Record not found", http.StatusNotFound)
+			return
+		}
+		if err := checkUnlocked(oldIn, oldOut); err != nil {
+			writeLockError(w, err)
+			return
+		}
+		if _, err := db.Exec("UPDATE dtr SET in_time=?, out_time=? WHERE id=?", in, out, id); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit(r, "dtr.edit", "dtr "+id, describeSession(in, out))
+	} else {
+		fid, err := strconv.Atoi(r.FormValue("faculty_id"))
+		if err != nil {
+			http.Error(w, "Missing faculty_id", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, in, out); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit(r, "dtr.add", fmt.Sprintf("faculty %d", fid), describeSession(in, out))
+	}
+
+	msg := "Time record saved."
+	if len(warnings) > 0 {
+		msg += " Please double-check: " + strings.Join(warnings, "; ") + "."
+	}
+	setFlash(w, r, msg)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleOvertimeApprove approves the overtime of dtr record id, so payroll
+// pays the overtime of that day (and, with CAP_AT_SHIFT_END, the time past
+// the shift end). approved=false withdraws the approval. Like edits, it is
+// refused inside a locked pay period.
+func handleOvertimeApprove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+	approved := true
+	if v := r.FormValue("approved"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid approved value, expected true or false", http.StatusBadRequest)
+			return
+		}
+		approved = b
+	}
+	var in, out sql.NullTime
+	if err := db.QueryRow("SELECT in_time, out_time FROM dtr WHERE id=?", id).Scan(&in, &out); err != nil {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	}
+	if err := checkUnlocked(in, out); err != nil {
+		writeLockError(w, err)
+		return
+	}
+	if _, err := db.Exec("UPDATE dtr SET overtime_approved=? WHERE id=?", approved, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "dtr.overtime", "dtr "+id, fmt.Sprintf("approved=%t", approved))
+	if approved {
+		setFlash(w, r, "Overtime approved.")
+	} else {
+		setFlash(w, r, "Overtime approval withdrawn.")
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// validateSession checks a manually entered session. An out time before the
+// in time is an error; very long sessions and sessions crossing midnight are
+// sometimes legitimate, so they only produce warnings.
+func validateSession(in time.Time, out sql.NullTime) (warnings []string, err error) {
+	if !out.Valid {
+		return nil, nil
+	}
+	if out.Time.Before(in) {
+		return nil, errors.New("Out time is before in time")
+	}
+	if h := out.Time.Sub(in).Hours(); h > maxSessionHours {
+		warnings = append(warnings, fmt.Sprintf("session is %.1f hours long", h))
+	}
+	if !dayStart(in).Equal(dayStart(out.Time)) {
+		warnings = append(warnings, "session spans midnight")
+	}
+	return warnings, nil
+}
+
+// describeSession formats in/out times for the audit log.
+func describeSession(in time.Time, out sql.NullTime) string {
+	s := "in " + in.Format("2006-01-02 15:04")
+	if out.Valid {
+		s += ", out " + out.Time.Format("2006-01-02 15:04")
+	}
+	return s
+}
+
+func writeLockError(w http.ResponseWriter, err error) {
+	var locked errPeriodLocked
+	if errors.As(err, &locked) {
+		http.Error(w, "Cannot change record: "+locked.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
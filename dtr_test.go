@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDTRSaveLockedPeriod(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	if _, err := db.Exec("INSERT INTO locked_periods (start_date, end_date, status) VALUES ('2026-10-01', '2026-10-15', 'locked')"); err != nil {
+		t.Fatal(err)
+	}
+	inside := addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:00")
+	outside := addSession(t, fid, "2026-10-20 08:00", "2026-10-20 12:00")
+	overnight := addSession(t, fid, "2026-09-30 22:00", "2026-10-01 06:00")
+	outOnly := addOutOnly(t, fid, "2026-10-20 17:00")
+	lockedOutOnly := addOutOnly(t, fid, "2026-10-14 17:00")
+
+	tests := []struct {
+		name    string
+		id      int
+		in, out string
+		want    int
+	}{
+		{"add outside", 0, "2026-10-21T08:00", "2026-10-21T12:00", http.StatusSeeOther},
+		{"add inside", 0, "2026-10-10T08:00", "2026-10-10T12:00", http.StatusConflict},
+		{"edit outside", outside, "2026-10-20T08:30", "2026-10-20T12:00", http.StatusSeeOther},
+		{"edit inside", inside, "2026-10-14T08:30", "2026-10-14T12:00", http.StatusConflict},
+		{"move out of the period", inside, "2026-10-20T08:00", "2026-10-20T12:00", http.StatusConflict},
+		{"move into the period", outside, "2026-10-14T08:00", "2026-10-14T12:00", http.StatusConflict},
+		{"add with out time inside", 0, "2026-09-29T22:00", "2026-10-01T06:00", http.StatusConflict},
+		{"edit with old out time inside", overnight, "2026-09-30T21:00", "2026-09-30T23:00", http.StatusConflict},
+		{"edit out-only row", outOnly, "2026-10-20T08:00", "2026-10-20T17:00", http.StatusSeeOther},
+		{"edit out-only row inside", lockedOutOnly, "2026-10-20T08:00", "2026-10-20T17:00", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"in": {tt.in}, "out": {tt.out}, "faculty_id": {strconv.Itoa(fid)}}
+			if tt.id != 0 {
+				form.Set("id", strconv.Itoa(tt.id))
+			}
+			req := httptest.NewRequest("POST", "/dtr/save", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleDTRSave(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if n := count(t, "SELECT COUNT(*) FROM dtr WHERE in_time=?", at(t, "2026-10-14 08:00")); n != 1 {
+		t.Error("a record inside the locked period was changed")
+	}
+}
+
+func TestValidateSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		in, out  string
+		wantErr  bool
+		warnings []string
+	}{
+		{"normal", "2026-10-14 08:00", "2026-10-14 17:00", false, nil},
+		{"open", "2026-10-14 08:00", "", false, nil},
+		{"out before in", "2026-10-14 17:00", "2026-10-14 08:00", true, nil},
+		{"18-hour span", "2026-10-14 06:00", "2026-10-14 23:59", false, []string{"session is 18.0 hours long"}},
+		{"overnight shift", "2026-10-14 22:00", "2026-10-15 06:00", false, []string{"session spans midnight"}},
+		{"long and overnight", "2026-10-14 08:00", "2026-10-15 02:00", false, []string{"session is 18.0 hours long", "session spans midnight"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out sql.NullTime
+			if tt.out != "" {
+				out = sql.NullTime{Time: at(t, tt.out), Valid: true}
+			}
+			warnings, err := validateSession(at(t, tt.in), out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("warnings %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+
+	// warnings don't block the save but are shown with it
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	form := url.Values{"in": {"2026-10-14T06:00"}, "out": {"2026-10-15T00:00"}, "faculty_id": {strconv.Itoa(fid)}}
+	req := signIn(t, httptest.NewRequest("POST", "/dtr/save", strings.NewReader(form.Encode())), roleAdmin)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handleDTRSave(rec, req)
+	if rec.Code != http.StatusSeeOther || count(t, "SELECT COUNT(*) FROM dtr") != 1 {
+		t.Fatalf("long session was not saved: %d %s", rec.Code, rec.Body)
+	}
+	home := httptest.NewRecorder()
+	handleHome(home, carryCookies(rec, httptest.NewRequest("GET", "/", nil)))
+	if !strings.Contains(home.Body.String(), "session is 18.0 hours long") {
+		t.Error("warning not shown after saving")
+	}
+}
+
+func TestOvertimeApproval(t *testing.T) {
+	resetDB(t)
+	setVar(t, &overtimeDailyHours, 8.0)
+	setVar(t, &overtimeRoleHours, map[string]float64{})
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	id := addSession(t, fid, "2026-10-14 08:00", "2026-10-14 18:00")
+	addSession(t, fid, "2026-10-15 08:00", "2026-10-15 15:00") // under the threshold
+	if _, err := db.Exec("INSERT INTO locked_periods (start_date, end_date, status) VALUES ('2026-10-01', '2026-10-10', 'locked')"); err != nil {
+		t.Fatal(err)
+	}
+	locked := addSession(t, fid, "2026-10-05 08:00", "2026-10-05 18:00")
+
+	approve := func(id int, approved string) int {
+		form := url.Values{"id": {strconv.Itoa(id)}}
+		if approved != "" {
+			form.Set("approved", approved)
+		}
+		req := httptest.NewRequest("POST", "/dtr/overtime", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleOvertimeApprove(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name     string
+		approved string // "-" skips the request
+		code     int
+		unpaid   float64
+		pay      float64
+	}{
+		{"unapproved overtime is not paid", "-", 0, 2, 1500},
+		{"approved overtime is paid", "", http.StatusSeeOther, 0, 1700},
+		{"withdrawn approval", "false", http.StatusSeeOther, 2, 1500},
+		{"invalid value", "maybe", http.StatusBadRequest, 2, 1500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.approved != "-" {
+				if code := approve(id, tt.approved); code != tt.code {
+					t.Fatalf("got %d, want %d", code, tt.code)
+				}
+			}
+			row := payrollByID(t, "2026-10-14", "2026-10-16")[fid]
+			if row.TotalHours != 17 || row.OvertimeHours != 2 || row.UnpaidOvertime != tt.unpaid || row.Pay != tt.pay {
+				t.Errorf("got %.2fh, %.2f overtime, %.2f unpaid, paid %.2f; want 17h, 2, %.2f, %.2f",
+					row.TotalHours, row.OvertimeHours, row.UnpaidOvertime, row.Pay, tt.unpaid, tt.pay)
+			}
+		})
+	}
+
+	if code := approve(locked, ""); code != http.StatusConflict {
+		t.Errorf("approval in a locked period: got %d, want 409", code)
+	}
+	if code := approve(999999, ""); code != http.StatusNotFound {
+		t.Errorf("unknown record: got %d, want 404", code)
+	}
+	if code := approve(addOutOnly(t, fid, "2026-10-20 17:00"), ""); code != http.StatusSeeOther {
+		t.Errorf("out-only record: got %d, want 303", code)
+	}
+	if code := approve(addOutOnly(t, fid, "2026-10-06 17:00"), ""); code != http.StatusConflict {
+		t.Errorf("out-only record in a locked period: got %d, want 409", code)
+	}
+}
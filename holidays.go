@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- HOLIDAYS ----------
+
+// holidayTypes are the accepted holiday kinds.
+var holidayTypes = map[string]bool{
+	"regular": true,
+	"special": true,
+}
+
+// holiday is a day on which worked hours earn Multiplier × the normal rate.
+type holiday struct {
+	Date       string
+	Type       string
+	Multiplier float64
+}
+
+// loadHolidays returns holidays on days in [from, to), keyed by "2006-01-02".
+func loadHolidays(from, to time.Time) (map[string]holiday, error) {
+	rs, err := db.Query("SELECT date, type, multiplier FROM holidays WHERE date >= ? AND date < ?",
+		from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	out := map[string]holiday{}
+	for rs.Next() {
+		var h holiday
+		if err := rs.Scan(&h.Date, &h.Type, &h.Multiplier); err != nil {
+			return nil, err
+		}
+		out[h.Date] = h
+	}
+	return out, rs.Err()
+}
+
+// parseHolidayRecord validates one CSV record of date, type, multiplier.
+func parseHolidayRecord(rec []string) (holiday, error) {
+	if len(rec) != 3 {
+		return holiday{}, fmt.Errorf("expected 3 columns (date,type,multiplier), got %d", len(rec))
+	}
+	date, typ, mult := strings.TrimSpace(rec[0]), strings.ToLower(strings.TrimSpace(rec[1])), strings.TrimSpace(rec[2])
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return holiday{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	if !holidayTypes[typ] {
+		return holiday{}, fmt.Errorf("unknown type %q (want regular or special)", typ)
+	}
+	m, err := parseImportNumber(mult)
+	if err != nil || m <= 0 {
+		return holiday{}, fmt.Errorf("multiplier must be a positive number, got %q", mult)
+	}
+	return holiday{Date: date, Type: typ, Multiplier: m}, nil
+}
+
+// handleHolidaysImport loads holidays from an uploaded CSV of
+// date,type,multiplier (an optional header row is skipped). Valid rows are
+// saved, replacing any holiday already on that date; malformed rows are
+// reported back by line. With dry_run=true nothing is saved.
+func handleHolidaysImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	file, ok := uploadedFile(w, r, "file")
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	recs, rowErrors, err := readImportCSV(file, "date")
+	if err != nil {
+		writeImportReadError(w, err)
+		return
+	}
+	var valid []holiday
+	var lines []int
+	for _, rec := range recs {
+		h, err := parseHolidayRecord(rec.Fields)
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{rec.Line, err.Error()})
+			continue
+		}
+		valid = append(valid, h)
+		lines = append(lines, rec.Line)
+	}
+	if dryRun, _ := strconv.ParseBool(r.FormValue("dry_run")); dryRun {
+		results := make([]importRowResult, len(valid))
+		seen := map[string]bool{}
+		for i, h := range valid {
+			var n int
+			if err := db.QueryRow("SELECT COUNT(*) FROM holidays WHERE date=?", h.Date).Scan(&n); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			results[i] = importRowResult{Line: lines[i], Result: "would-insert"}
+			if n > 0 || seen[h.Date] {
+				results[i].Result = "would-replace"
+			}
+			seen[h.Date] = true
+		}
+		writeImportPreview(w, results, rowErrors)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for _, h := range valid {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO holidays (date, type, multiplier) VALUES (?,?,?)", h.Date, h.Type, h.Multiplier); err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "holidays.import", "holidays", fmt.Sprintf("imported %d, rejected %d", len(valid), len(rowErrors)))
+
+	writeImportResult(w, len(valid), rowErrors)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHolidaysImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		want     int
+		imported int
+		errLines []int
+	}{
+		{"valid", "date,type,multiplier\n2026-12-25,regular,2\n2026-11-01,special,1.3\n", http.StatusOK, 2, nil},
+		{"invalid date", "2026-12-25,regular,2\n2026-02-30,special,1.3\n2026-11-01,special,1.3\n", http.StatusUnprocessableEntity, 2, []int{2}},
+		{"malformed row", "date,type,multiplier\n2026-12-25,regular,2\n2026-11-\"01,special,1.3\n", http.StatusUnprocessableEntity, 1, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			rec := httptest.NewRecorder()
+			handleHolidaysImport(rec, uploadRequest(t, "/holidays/import", "file", tt.csv, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			var res struct {
+				Imported int              `json:"imported"`
+				Errors   []importRowError `json:"errors"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			var lines []int
+			for _, e := range res.Errors {
+				lines = append(lines, e.Line)
+			}
+			if res.Imported != tt.imported || fmt.Sprint(lines) != fmt.Sprint(tt.errLines) {
+				t.Errorf("imported %d with errors on lines %v, want %d and %v", res.Imported, lines, tt.imported, tt.errLines)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM holidays"); n != tt.imported {
+				t.Errorf("%d holidays saved, want %d", n, tt.imported)
+			}
+		})
+	}
+}
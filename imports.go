@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------- CSV IMPORTS ----------
+
+// importRecord is one data row of an uploaded CSV and its line number.
+type importRecord struct {
+	Line   int
+	Fields []string
+}
+
+type importRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// errImportTooLarge refuses a file with more than maxImportRows rows; Line
+// is the first line over the cap.
+type errImportTooLarge struct{ Line int }
+
+func (e errImportTooLarge) Error() string {
+	return fmt.Sprintf("Import has more than %d rows (line %d is over the limit); split the file and try again", maxImportRows, e.Line)
+}
+
+// readImportCSV reads every record of an import file, skipping a header row
+// whose first column is headerCol. Unparseable lines are returned as row
+// errors. A file with more than maxImportRows rows is refused outright with
+// errImportTooLarge so a runaway upload never gets partially imported.
+func readImportCSV(r io.Reader, headerCol string) ([]importRecord, []importRowError, error) {
+	var recs []importRecord
+	rowErrors := []importRowError{}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var line int
+		if err != nil {
+			// FieldPos is only valid after a successful Read
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, nil, err
+			}
+			line = perr.Line
+		} else {
+			line, _ = cr.FieldPos(0)
+			if line == 1 && len(rec) > 0 && strings.EqualFold(strings.TrimSpace(rec[0]), headerCol) {
+				continue
+			}
+		}
+		if len(recs)+len(rowErrors) >= maxImportRows {
+			return nil, nil, errImportTooLarge{line}
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{line, err.Error()})
+			continue
+		}
+		recs = append(recs, importRecord{line, rec})
+	}
+	return recs, rowErrors, nil
+}
+
+// writeImportReadError answers a readImportCSV failure: 413 for a file over
+// the row cap, 400 for one that couldn't be read at all.
+func writeImportReadError(w http.ResponseWriter, err error) {
+	if errors.As(err, &errImportTooLarge{}) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Could not read import file: "+err.Error(), http.StatusBadRequest)
+}
+
+// parseImportNumber parses a number from an import file, reading
+// importDecimal as the decimal point.
+func parseImportNumber(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if importDecimal == "," {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// writeImportResult reports how many rows were imported and which were
+// rejected; any rejected row makes the response a 422.
+func writeImportResult(w http.ResponseWriter, imported int, rowErrors []importRowError) {
+	code := http.StatusOK
+	if len(rowErrors) > 0 {
+		code = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, code, struct {
+		Imported int              `json:"imported"`
+		Errors   []importRowError `json:"errors"`
+	}{imported, rowErrors})
+}
+
+// importRowResult is what a dry-run import would do with one row:
+// "would-insert", "would-replace" or "error".
+type importRowResult struct {
+	Line   int    `json:"line"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// writeImportPreview reports, row by row in file order, what an import would
+// do without having written anything. As with a real import, any rejected
+// row makes the response a 422.
+func writeImportPreview(w http.ResponseWriter, results []importRowResult, rowErrors []importRowError) {
+	for _, e := range rowErrors {
+		results = append(results, importRowResult{Line: e.Line, Result: "error", Error: e.Error})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+	code := http.StatusOK
+	if len(rowErrors) > 0 {
+		code = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, code, struct {
+		DryRun      bool              `json:"dry_run"`
+		WouldImport int               `json:"would_import"`
+		Rows        []importRowResult `json:"rows"`
+		Errors      []importRowError  `json:"errors"`
+	}{true, len(results) - len(rowErrors), results, rowErrors})
+}
+
+// facultyImport is a validated row of a faculty import.
+type facultyImport struct {
+	line       int
+	name, role string
+	rate       float64
+	token      string
+}
+
+// handleFacultyImport adds faculty from an uploaded CSV of
+// name,role,rate[,token] (an optional header row is skipped). Rows get the
+// default schedule and a random token unless one is given; each gets a QR.
+// Malformed rows are reported back by line and the rest are imported. With
+// dry_run=true every row is validated and reported but nothing is saved.
+func handleFacultyImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	file, ok := uploadedFile(w, r, "file")
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	recs, rowErrors, err := readImportCSV(file, "name")
+	if err != nil {
+		writeImportReadError(w, err)
+		return
+	}
+
+	seen := map[string]bool{}
+	var valid []facultyImport
+	for _, rec := range recs {
+		f, err := parseFacultyRecord(rec, seen)
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{rec.Line, err.Error()})
+			continue
+		}
+		valid = append(valid, f)
+	}
+	if dryRun, _ := strconv.ParseBool(r.FormValue("dry_run")); dryRun {
+		results := make([]importRowResult, len(valid))
+		for i, f := range valid {
+			results[i] = importRowResult{Line: f.line, Result: "would-insert"}
+		}
+		writeImportPreview(w, results, rowErrors)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	ids := make([]int64, len(valid))
+	for i, f := range valid {
+		res, err := tx.Exec("INSERT INTO faculty (name,role,rate_per_hour,token,work_days) VALUES (?,?,?,?,?)",
+			f.name, f.role, f.rate, f.token, defaultWorkDays)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		ids[i], _ = res.LastInsertId()
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	imported := 0
+	for i, f := range valid {
+		if err := writeQR(r.Host, f.token, f.name, f.role); err != nil {
+			// same as a single add: never list a faculty without a card
+			_, _ = db.Exec("DELETE FROM faculty WHERE id=?", ids[i])
+			rowErrors = append(rowErrors, importRowError{f.line, "failed to generate QR: " + err.Error()})
+			continue
+		}
+		imported++
+	}
+	audit(r, "faculty.import", "faculty", fmt.Sprintf("imported %d, rejected %d", imported, len(rowErrors)))
+	writeImportResult(w, imported, rowErrors)
+}
+
+// parseFacultyRecord validates one faculty import row. seen holds tokens
+// already claimed earlier in the same file.
+func parseFacultyRecord(rec importRecord, seen map[string]bool) (facultyImport, error) {
+	f := facultyImport{line: rec.Line}
+	if n := len(rec.Fields); n < 3 || n > 4 {
+		return f, fmt.Errorf("expected 3 or 4 columns (name,role,rate[,token]), got %d", n)
+	}
+	f.name = strings.TrimSpace(rec.Fields[0])
+	if f.name == "" {
+		return f, errors.New("name is required")
+	}
+	role, err := checkRole(strings.TrimSpace(rec.Fields[1]))
+	if err != nil {
+		return f, err
+	}
+	f.role = role
+	rate, err := parseImportNumber(rec.Fields[2])
+	if err != nil || rate < 0 {
+		return f, fmt.Errorf("invalid rate %q", rec.Fields[2])
+	}
+	f.rate = rate
+
+	if len(rec.Fields) == 4 && strings.TrimSpace(rec.Fields[3]) != "" {
+		f.token = strings.TrimSpace(rec.Fields[3])
+		if !tokenPattern.MatchString(f.token) {
+			return f, fmt.Errorf("invalid token %q", f.token)
+		}
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM faculty WHERE token=?", f.token).Scan(&n); err != nil {
+			return f, err
+		}
+		if n > 0 || seen[f.token] {
+			return f, fmt.Errorf("token %q already in use", f.token)
+		}
+	} else {
+		f.token = randToken()
+	}
+	seen[f.token] = true
+	return f, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFacultyImportRowCap(t *testing.T) {
+	setVar(t, &maxImportRows, 3)
+	tests := []struct {
+		name     string
+		csv      string
+		want     int
+		inserted int
+		message  string
+	}{
+		{"within the cap", "name,role,rate\nAna Cruz,Teacher,100\nBen Reyes,Teacher,100\nCora Lim,Staff,90\n", http.StatusOK, 3, `"imported":3`},
+		{"over the cap", "name,role,rate\nAna Cruz,Teacher,100\nBen Reyes,Teacher,100\nCora Lim,Staff,90\nDan Sy,Staff,90\n", http.StatusRequestEntityTooLarge, 0, "line 5 is over the limit"},
+		{"over the cap with a malformed row", "Ana Cruz,Teacher,100\nBen \"Reyes,Teacher,100\nCora Lim,Staff,90\nDan Sy,Staff,90\n", http.StatusRequestEntityTooLarge, 0, "line 4 is over the limit"},
+		{"malformed row", "name,role,rate\n\"Ana\" Cruz,Teacher,100\n", http.StatusUnprocessableEntity, 0, `"line":2`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			rec := httptest.NewRecorder()
+			handleFacultyImport(rec, uploadRequest(t, "/faculty/import", "file", tt.csv, nil))
+			if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.message) {
+				t.Errorf("got %d %s, want %d with %s", rec.Code, rec.Body, tt.want, tt.message)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM faculty"); n != tt.inserted {
+				t.Errorf("%d faculty inserted, want %d", n, tt.inserted)
+			}
+		})
+	}
+}
+
+func TestImportDecimalSeparator(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		faculty   string
+		holiday   string
+		rate      float64 // 0 when the faculty row is refused
+		mult      float64 // 0 when the holiday row is refused
+	}{
+		{"dot by default", ".", "Ana Cruz,Teacher,12.50", "2026-12-25,regular,2.5", 12.5, 2.5},
+		{"comma refused by default", ".", `Ana Cruz,Teacher,"12,50"`, `2026-12-25,regular,"2,5"`, 0, 0},
+		{"comma mode", ",", `Ana Cruz,Teacher,"12,50"`, `2026-12-25,regular,"2,5"`, 12.5, 2.5},
+		{"comma mode keeps whole numbers", ",", "Ana Cruz,Teacher,100", "2026-12-25,regular,2", 100, 2},
+		{"comma mode refuses a dot and a comma", ",", `Ana Cruz,Teacher,"1.234,50"`, `2026-12-25,regular,"1.2,5"`, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &importDecimal, tt.separator)
+
+			rec := httptest.NewRecorder()
+			handleFacultyImport(rec, uploadRequest(t, "/faculty/import", "file", "name,role,rate\n"+tt.faculty+"\n", nil))
+			if (rec.Code == http.StatusOK) != (tt.rate != 0) {
+				t.Fatalf("faculty import: got %d %s", rec.Code, rec.Body)
+			}
+			if tt.rate != 0 {
+				var rate float64
+				if err := db.QueryRow("SELECT rate_per_hour FROM faculty WHERE name='Ana Cruz'").Scan(&rate); err != nil {
+					t.Fatal(err)
+				}
+				if rate != tt.rate {
+					t.Errorf("rate %.2f, want %.2f", rate, tt.rate)
+				}
+			}
+
+			rec = httptest.NewRecorder()
+			handleHolidaysImport(rec, uploadRequest(t, "/holidays/import", "file", "date,type,multiplier\n"+tt.holiday+"\n", nil))
+			if (rec.Code == http.StatusOK) != (tt.mult != 0) {
+				t.Fatalf("holiday import: got %d %s", rec.Code, rec.Body)
+			}
+			if tt.mult != 0 {
+				var mult float64
+				if err := db.QueryRow("SELECT multiplier FROM holidays WHERE date='2026-12-25'").Scan(&mult); err != nil {
+					t.Fatal(err)
+				}
+				if mult != tt.mult {
+					t.Errorf("multiplier %.2f, want %.2f", mult, tt.mult)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		csv    string
+		dryRun string
+		want   int
+		rows   []string // line:result
+		saved  int
+		table  string
+		setup  string
+	}{
+		{"faculty preview", "/faculty/import",
+			"name,role,rate,token\nAna Cruz,Teacher,100\nBen Reyes,Teacher,abc\nCora Lim,Staff,90,taken123\nDan Sy,Staff,90\n",
+			"true", http.StatusUnprocessableEntity,
+			[]string{"2:would-insert", "3:error", "4:error", "5:would-insert"}, 0, "faculty",
+			"INSERT INTO faculty (name, role, rate_per_hour, token) VALUES ('Old Hand', 'Staff', 90, 'taken123')"},
+		{"faculty preview of a clean file", "/faculty/import",
+			"Ana Cruz,Teacher,100\nBen Reyes,Teacher,100\n",
+			"true", http.StatusOK, []string{"1:would-insert", "2:would-insert"}, 0, "faculty", ""},
+		{"faculty import commits", "/faculty/import",
+			"Ana Cruz,Teacher,100\nBen Reyes,Teacher,100\n",
+			"false", http.StatusOK, nil, 2, "faculty", ""},
+		{"holiday preview", "/holidays/import",
+			"date,type,multiplier\n2026-12-25,regular,2\n2026-02-30,special,1.3\n2026-11-01,special,1.3\n2026-11-01,special,1.5\n",
+			"true", http.StatusUnprocessableEntity,
+			[]string{"2:would-replace", "3:error", "4:would-insert", "5:would-replace"}, 0, "holidays",
+			"INSERT INTO holidays (date, type, multiplier) VALUES ('2026-12-25', 'regular', 2)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			if tt.setup != "" {
+				if _, err := db.Exec(tt.setup); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := count(t, "SELECT COUNT(*) FROM "+tt.table)
+			rec := httptest.NewRecorder()
+			req := uploadRequest(t, tt.path, "file", tt.csv, url.Values{"dry_run": {tt.dryRun}})
+			if tt.table == "faculty" {
+				handleFacultyImport(rec, req)
+			} else {
+				handleHolidaysImport(rec, req)
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM "+tt.table) - before; n != tt.saved {
+				t.Errorf("%d rows saved, want %d", n, tt.saved)
+			}
+			if tt.dryRun != "true" {
+				return
+			}
+			var res struct {
+				DryRun      bool              `json:"dry_run"`
+				WouldImport int               `json:"would_import"`
+				Rows        []importRowResult `json:"rows"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			var rows []string
+			imports := 0
+			for _, row := range res.Rows {
+				rows = append(rows, fmt.Sprintf("%d:%s", row.Line, row.Result))
+				if row.Result == "error" && row.Error == "" {
+					t.Errorf("line %d: error without a message", row.Line)
+				}
+				if row.Result != "error" {
+					imports++
+				}
+			}
+			if !res.DryRun || res.WouldImport != imports || fmt.Sprint(rows) != fmt.Sprint(tt.rows) {
+				t.Errorf("got %+v, want rows %v", res, tt.rows)
+			}
+			if files, _ := filepath.Glob(filepath.Join(qrDir, "*")); len(files) != 0 {
+				t.Errorf("dry run wrote %d QR files", len(files))
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log"); n != 0 {
+				t.Errorf("dry run wrote %d audit entries", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ---------- LOGO ----------
+
+// logoImageType is the gofpdf image type of the configured logo, or "" when
+// the file is missing or unsupported and should be left off PDFs. logoMu
+// guards it, and holds the file at logoPath still while it is read, so an
+// upload swapping the file never pairs the new file with the old type.
+var (
+	logoMu        sync.RWMutex
+	logoImageType string
+)
+
+// supportedLogoTypes maps sniffed content types to gofpdf image types.
+var supportedLogoTypes = map[string]string{
+	"image/png":  "PNG",
+	"image/jpeg": "JPG",
+	"image/gif":  "GIF",
+}
+
+// checkLogo validates logoPath at startup, warning instead of failing so a
+// bad logo never keeps the DTR from running.
+func checkLogo() {
+	logoMu.Lock()
+	defer logoMu.Unlock()
+	logoImageType = sniffLogo()
+}
+
+// sniffLogo returns the gofpdf image type of the file at logoPath, or "" with
+// a warning logged when it is missing or unsupported.
+func sniffLogo() string {
+	f, err := os.Open(logoPath)
+	if err != nil {
+		log.Printf("warning: logo %s: %v; PDFs will be printed without a logo", logoPath, err)
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	ct := http.DetectContentType(head[:n])
+	t, ok := supportedLogoTypes[ct]
+	if !ok {
+		log.Printf("warning: logo %s has unsupported type %s (want PNG, JPEG or GIF); PDFs will be printed without a logo", logoPath, ct)
+		return ""
+	}
+	return t
+}
+
+// drawLogo places the logo with its top-left at x, y and the given height,
+// keeping the aspect ratio. It does nothing when no valid logo is configured.
+func drawLogo(pdf *gofpdf.Fpdf, x, y, h float64) {
+	logoMu.RLock()
+	defer logoMu.RUnlock()
+	if logoImageType == "" {
+		return
+	}
+	pdf.ImageOptions(logoPath, x, y, 0, h, false, gofpdf.ImageOptions{ImageType: logoImageType, ReadDpi: true}, 0, "")
+}
+
+// logoWidth returns the width the logo takes up when drawn at height h.
+func logoWidth(pdf *gofpdf.Fpdf, h float64) float64 {
+	logoMu.RLock()
+	defer logoMu.RUnlock()
+	if logoImageType == "" {
+		return 0
+	}
+	info := pdf.RegisterImageOptions(logoPath, gofpdf.ImageOptions{ImageType: logoImageType, ReadDpi: true})
+	if info == nil || info.Height() == 0 {
+		return 0
+	}
+	return h * info.Width() / info.Height()
+}
+
+// handleLogo serves the configured logo for HTML page headers. The file is
+// opened under logoMu but served after releasing it, so a slow client never
+// holds up an upload; the open file outlives a rename over logoPath.
+func handleLogo(w http.ResponseWriter, r *http.Request) {
+	logoMu.RLock()
+	var f *os.File
+	if logoImageType != "" {
+		f, _ = os.Open(logoPath)
+	}
+	logoMu.RUnlock()
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	var mod time.Time
+	if fi, err := f.Stat(); err == nil {
+		mod = fi.ModTime()
+	}
+	http.ServeContent(w, r, filepath.Base(logoPath), mod, f)
+}
+
+// maxLogoBytes caps an uploaded logo; it is drawn a few centimetres tall, so
+// anything larger is wasted in every PDF.
+const maxLogoBytes = 2 << 20
+
+// handleLogoUpload replaces the logo at logoPath with an uploaded PNG, JPEG
+// or GIF. The file is swapped in atomically and takes effect on the next
+// page or PDF without a restart.
+func handleLogoUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	file, ok := uploadedFile(w, r, "logo")
+	if !ok {
+		return
+	}
+	defer file.Close()
+	b, err := io.ReadAll(io.LimitReader(file, maxLogoBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(b) > maxLogoBytes {
+		http.Error(w, fmt.Sprintf("Logo too large (max %d MB)", maxLogoBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	ct := http.DetectContentType(b)
+	typ, ok := supportedLogoTypes[ct]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unsupported logo type %s (want PNG, JPEG or GIF)", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(b)); err != nil {
+		http.Error(w, "Logo is not a readable image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logoPath), 0o755); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	// each upload writes its own temporary file, so two at once can't mix
+	tmp, err := os.CreateTemp(filepath.Dir(logoPath), ".logo-*")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	// the file and its type change together, under the lock the readers take
+	logoMu.Lock()
+	err = os.Rename(tmp.Name(), logoPath)
+	if err == nil {
+		logoImageType = typ
+	}
+	logoMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "logo.upload", logoPath, fmt.Sprintf("%s, %d bytes", ct, len(b)))
+	setFlash(w, r, "Logo updated.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// testPNG encodes a small image as PNG.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLogoPath(t *testing.T) {
+	dir := t.TempDir()
+	logo := testPNG(t)
+	if err := os.WriteFile(filepath.Join(dir, "crest.png"), logo, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "crest.txt"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantType string
+		want     int
+	}{
+		{"configured", filepath.Join(dir, "crest.png"), "PNG", http.StatusOK},
+		{"missing", filepath.Join(dir, "nope.png"), "", http.StatusNotFound},
+		{"unsupported", filepath.Join(dir, "crest.txt"), "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &logoPath, tt.path)
+			setVar(t, &logoImageType, "")
+			checkLogo()
+			if logoImageType != tt.wantType {
+				t.Errorf("logo type %q, want %q", logoImageType, tt.wantType)
+			}
+			rec := httptest.NewRecorder()
+			handleLogo(rec, httptest.NewRequest("GET", "/logo", nil))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), logo) {
+				t.Error("served a different logo than the configured one")
+			}
+		})
+	}
+}
+
+// testJPEG encodes a small image as JPEG.
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLogoUpload(t *testing.T) {
+	old := testPNG(t)
+	tests := []struct {
+		name     string
+		content  []byte
+		want     int
+		wantType string
+	}{
+		{"png", testPNG(t), http.StatusSeeOther, "PNG"},
+		{"jpeg", testJPEG(t), http.StatusSeeOther, "JPG"},
+		{"not an image", []byte("name,role,rate\n"), http.StatusUnsupportedMediaType, "PNG"},
+		{"truncated png", old[:20], http.StatusBadRequest, "PNG"},
+		{"too large", append(testPNG(t), make([]byte, maxLogoBytes)...), http.StatusRequestEntityTooLarge, "PNG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			// registered first so it runs after logoPath is restored
+			t.Cleanup(checkLogo)
+			setVar(t, &logoPath, filepath.Join(t.TempDir(), "img", "logo.png"))
+			if err := os.MkdirAll(filepath.Dir(logoPath), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(logoPath, old, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			checkLogo()
+
+			req := uploadRequest(t, "/admin/logo", "logo", string(tt.content), nil)
+			rec := httptest.NewRecorder()
+			handleLogoUpload(rec, signIn(t, req, "admin"))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			ok := tt.want == http.StatusSeeOther
+			if logoImageType != tt.wantType {
+				t.Errorf("logo type %q, want %q", logoImageType, tt.wantType)
+			}
+			served := httptest.NewRecorder()
+			handleLogo(served, httptest.NewRequest("GET", "/logo", nil))
+			want := old
+			if ok {
+				want = tt.content
+			}
+			if !bytes.Equal(served.Body.Bytes(), want) {
+				t.Errorf("serving %d bytes, want %d", served.Body.Len(), len(want))
+			}
+			if files, _ := filepath.Glob(filepath.Join(filepath.Dir(logoPath), ".logo-*")); len(files) != 0 {
+				t.Errorf("temporary files left: %v", files)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action='logo.upload'"); (n == 1) != ok {
+				t.Errorf("%d audit entries", n)
+			}
+		})
+	}
+}
+
+// TestLogoUploadConcurrent swaps PNG and JPEG logos while PDFs and pages read
+// the logo; run with -race. The logo type must always describe the file.
+func TestLogoUploadConcurrent(t *testing.T) {
+	resetDB(t)
+	t.Cleanup(checkLogo)
+	setVar(t, &logoPath, filepath.Join(t.TempDir(), "logo.png"))
+	checkLogo()
+	logos := [][]byte{testPNG(t), testJPEG(t)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handleLogoUpload(rec, uploadRequest(t, "/admin/logo", "logo", string(logos[i%2]), nil))
+			if rec.Code != http.StatusSeeOther {
+				t.Errorf("upload: got %d: %s", rec.Code, rec.Body)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			pdf := gofpdf.New("P", "mm", "A4", "")
+			pdf.AddPage()
+			logoWidth(pdf, 10)
+			drawLogo(pdf, 10, 10, 10)
+			if err := pdf.Error(); err != nil {
+				t.Errorf("pdf: %v", err)
+			}
+			handleLogo(httptest.NewRecorder(), httptest.NewRequest("GET", "/logo", nil))
+		}()
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(logoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := supportedLogoTypes[http.DetectContentType(b)]
+	if logoImageType != want {
+		t.Errorf("logo type %q, but the file is %q", logoImageType, want)
+	}
+	if files, _ := filepath.Glob(filepath.Join(filepath.Dir(logoPath), ".logo-*")); len(files) != 0 {
+		t.Errorf("temporary files left: %v", files)
+	}
+}
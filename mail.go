@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ---------- MAIL ----------
+
+// mailer sends a message with plain-text and HTML alternatives.
+type mailer interface {
+	Send(to []string, subject, text, html string) error
+}
+
+// smtpMailer sends through the SMTP_* settings with PLAIN auth when a user is set.
+type smtpMailer struct {
+	host, port, user, pass, from string
+}
+
+func (m smtpMailer) Send(to []string, subject, text, html string) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ ctype, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.ctype}})
+		if err != nil {
+			return err
+		}
+		if _, err := pw.Write([]byte(part.content)); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.pass, m.host)
+	}
+	return smtp.SendMail(m.host+":"+m.port, auth, m.from, to, msg.Bytes())
+}
+
+// configuredMailer returns the SMTP mailer, or nil when SMTP isn't configured.
+// It is a variable so tests can substitute a fake.
+var configuredMailer = func() mailer {
+	if smtpHost == "" || smtpFrom == "" {
+		return nil
+	}
+	return smtpMailer{host: smtpHost, port: smtpPort, user: smtpUser, pass: smtpPass, from: smtpFrom}
+}
+
+// handleTestEmail sends a short message to the posted address through the
+// configured SMTP settings and reports the exact error if it fails, so mail
+// can be checked before relying on the scheduled reports.
+func handleTestEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(r.FormValue("to")))
+	if err != nil {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+	m := configuredMailer()
+	if m == nil {
+		http.Error(w, "SMTP is not configured (set SMTP_HOST and SMTP_FROM)", http.StatusServiceUnavailable)
+		return
+	}
+	subject := "DTR test email"
+	text := fmt.Sprintf("This is a test message from %s, sent %s.\n", appTitle, appNow().Format("2006-01-02 15:04"))
+	html := "<p>" + template.HTMLEscapeString(text) + "</p>"
+	if err := m.Send([]string{addr.Address}, subject, text, html); err != nil {
+		logf(r, "test email to %s: %v", addr.Address, err)
+		audit(r, "mail.test", addr.Address, "failed: "+err.Error())
+		setFlash(w, r, "Test email failed: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	audit(r, "mail.test", addr.Address, "sent")
+	setFlash(w, r, fmt.Sprintf("Test email sent to %s via %s:%s.", addr.Address, smtpHost, smtpPort))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ---------- DAILY SUMMARY ----------
+
+type dailySummary struct {
+	Date       string
+	Present    int
+	Absent     int
+	TotalHours float64
+	Anomalies  []anomaly
+}
+
+// buildDailySummary gathers the day's attendance figures and anomalies.
+func buildDailySummary(day time.Time) (dailySummary, error) {
+	from := dayStart(day)
+	sum := dailySummary{Date: from.Format("2006-01-02")}
+	rows, err := dayReport(from)
+	if err != nil {
+		return sum, err
+	}
+	for _, row := range rows {
+		switch row.Status() {
+		case "present":
+			sum.Present++
+		case "absent":
+			sum.Absent++
+		}
+		sum.TotalHours += row.Hours
+	}
+	// anomalies over the past week so a forgotten clock-out keeps being reported
+	sum.Anomalies, err = findAnomalies(from.AddDate(0, 0, -6), from.AddDate(0, 0, 1))
+	return sum, err
+}
+
+var summaryHTML = template.Must(template.New("summary").Parse(`<h2>Attendance summary for {{.Date}}</h2>
+<p>Present: <b>{{.Present}}</b> • Absent: <b>{{.Absent}}</b> • Total hours: <b>{{printf "%.2f" .TotalHours}}</b></p>
+{{if .Anomalies}}<h3>Anomalies</h3>
+<ul>{{range .Anomalies}}<li><b>{{.Name}}</b>: {{.Kind}} ({{.Detail}})</li>{{end}}</ul>
+{{else}}<p>No anomalies.</p>{{end}}`))
+
+// composeDailySummary renders the summary as an email subject, text and HTML body.
+func composeDailySummary(sum dailySummary) (subject, text, html string, err error) {
+	subject = fmt.Sprintf("DTR summary %s: %d present, %d absent", sum.Date, sum.Present, sum.Absent)
+
+	var t strings.Builder
+	fmt.Fprintf(&t, "Attendance summary for %s\n\n", sum.Date)
+	fmt.Fprintf(&t, "Present: %d\nAbsent: %d\nTotal hours: %.2f\n\n", sum.Present, sum.Absent, sum.TotalHours)
+	if len(sum.Anomalies) == 0 {
+		t.WriteString("No anomalies.\n")
+	} else {
+		t.WriteString("Anomalies:\n")
+		for _, a := range sum.Anomalies {
+			fmt.Fprintf(&t, "- %s: %s (%s)\n", a.Name, a.Kind, a.Detail)
+		}
+	}
+
+	var h bytes.Buffer
+	if err := summaryHTML.Execute(&h, sum); err != nil {
+		return "", "", "", err
+	}
+	return subject, t.String(), h.String(), nil
+}
+
+// sendDailySummary composes and mails the summary for day.
+func sendDailySummary(m mailer, day time.Time) error {
+	sum, err := buildDailySummary(day)
+	if err != nil {
+		return err
+	}
+	subject, text, html, err := composeDailySummary(sum)
+	if err != nil {
+		return err
+	}
+	return m.Send(summaryRecipients, subject, text, html)
+}
+
+// nextRun returns the next occurrence of hh:mm in appLoc strictly after now.
+func nextRun(now time.Time, hour, min int) time.Time {
+	now = now.In(appLoc)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, appLoc)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// startDailySummaryJob emails the summary every day at summaryHour:summaryMinute.
+// It does nothing when SMTP or recipients aren't configured.
+func startDailySummaryJob() {
+	m := configuredMailer()
+	if m == nil || len(summaryRecipients) == 0 {
+		log.Println("daily summary email disabled (set SMTP_HOST, SMTP_FROM and SUMMARY_RECIPIENTS to enable)")
+		return
+	}
+	go func() {
+		for {
+			next := nextRun(time.Now(), summaryHour, summaryMinute)
+			time.Sleep(time.Until(next))
+			if err := sendDailySummary(m, next); err != nil {
+				log.Printf("daily summary: %v", err)
+				continue
+			}
+			log.Printf("daily summary for %s sent to %s", next.Format("2006-01-02"), strings.Join(summaryRecipients, ", "))
+		}
+	}()
+}
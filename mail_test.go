@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeMailer records what would have been sent.
+type fakeMailer struct {
+	to                  []string
+	subject, text, html string
+	sent                int
+	err                 error // returned instead of sending
+}
+
+func (m *fakeMailer) Send(to []string, subject, text, html string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.to, m.subject, m.text, m.html = to, subject, text, html
+	m.sent++
+	return nil
+}
+
+func TestDailySummary(t *testing.T) {
+	setVar(t, &summaryRecipients, []string{"principal@example.edu"})
+	tests := []struct {
+		name     string
+		seed     func(ana, ben, cora int)
+		subject  string
+		contains []string
+	}{
+		{
+			"quiet day",
+			func(ana, ben, cora int) {
+				addSession(t, ana, "2026-10-14 08:00", "2026-10-14 16:00")
+			},
+			"DTR summary 2026-10-14: 1 present, 2 absent",
+			[]string{"Present: 1\nAbsent: 2\nTotal hours: 8.00", "No anomalies."},
+		},
+		{
+			"with anomalies",
+			func(ana, ben, cora int) {
+				addSession(t, ana, "2026-10-14 08:00", "2026-10-14 16:00")
+				addSession(t, cora, "2026-10-13 08:00", "")
+				addSession(t, cora, "2026-10-14 09:00", "2026-10-14 13:00")
+			},
+			"DTR summary 2026-10-14: 2 present, 1 absent",
+			[]string{"Present: 2\nAbsent: 1\nTotal hours: 12.00", "Anomalies:\n- Cora <Lim>: open session (clocked in 2026-10-13 08:00 with no clock-out)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			ana, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			ben, _ := addFaculty(t, "Ben Reyes", "Teacher", 100)
+			cora, _ := addFaculty(t, "Cora <Lim>", "Teacher", 100)
+			tt.seed(ana, ben, cora)
+
+			m := &fakeMailer{}
+			if err := sendDailySummary(m, at(t, "2026-10-14 18:00")); err != nil {
+				t.Fatal(err)
+			}
+			if m.sent != 1 || len(m.to) != 1 || m.to[0] != "principal@example.edu" {
+				t.Fatalf("sent %d messages to %v", m.sent, m.to)
+			}
+			if m.subject != tt.subject {
+				t.Errorf("subject %q, want %q", m.subject, tt.subject)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(m.text, s) {
+					t.Errorf("text body lacks %q:\n%s", s, m.text)
+				}
+			}
+			if strings.Contains(m.html, "<Lim>") {
+				t.Error("HTML body does not escape names")
+			}
+		})
+	}
+}
+
+func TestTestEmail(t *testing.T) {
+	refused := errors.New("535 5.7.8 authentication failed")
+	tests := []struct {
+		name   string
+		to     string
+		mailer *fakeMailer // nil leaves SMTP unconfigured
+		code   int
+		flash  string
+		audit  string
+	}{
+		{"sent", "clerk@example.edu", &fakeMailer{}, http.StatusSeeOther, "Test email sent to clerk@example.edu", "sent"},
+		{"smtp error", "clerk@example.edu", &fakeMailer{err: refused}, http.StatusSeeOther, "Test email failed: " + refused.Error(), "failed: " + refused.Error()},
+		{"not configured", "clerk@example.edu", nil, http.StatusServiceUnavailable, "", ""},
+		{"bad address", "not an address", &fakeMailer{}, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &configuredMailer, func() mailer {
+				if tt.mailer == nil {
+					return nil
+				}
+				return tt.mailer
+			})
+			form := url.Values{"to": {tt.to}}
+			req := signIn(t, httptest.NewRequest("POST", "/admin/test-email", strings.NewReader(form.Encode())), "admin")
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleTestEmail(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.flash == "" {
+				if tt.mailer != nil && tt.mailer.sent != 0 {
+					t.Error("a message was sent")
+				}
+				return
+			}
+			if got := getFlash(httptest.NewRecorder(), carryCookies(rec, httptest.NewRequest("GET", "/", nil))); !strings.Contains(got, tt.flash) {
+				t.Errorf("flash %q, want %q", got, tt.flash)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action='mail.test' AND detail=?", tt.audit); n != 1 {
+				t.Errorf("no audit entry with detail %q", tt.audit)
+			}
+			if tt.mailer.err == nil && (tt.mailer.sent != 1 || tt.mailer.to[0] != tt.to) {
+				t.Errorf("sent %d messages to %v", tt.mailer.sent, tt.mailer.to)
+			}
+		})
+	}
+}
@@ -1,23 +1,32 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"embed"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"math"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/jung-kurt/gofpdf"
+	"github.com/jung-kurt/gofpdf/contrib/barcode"
 	qrcode "github.com/skip2/go-qrcode"
 	_ "modernc.org/sqlite"
 )
@@ -27,6 +36,10 @@ var (
 	tplIndex   *template.Template
 	tplPayroll *template.Template
 	tplLogin   *template.Template
+	tplDay     *template.Template
+	tplAudit   *template.Template
+	tplCard    *template.Template
+	tplBoard   *template.Template
 )
 
 // directories
@@ -37,17 +50,19 @@ var pdfDir = "data/pdf"
 var tplFS embed.FS
 
 // ---------- AUTH ----------
+// default admin account, seeded into the admins table on first run
 var adminUser = "slacadmin"
 var adminPass = "slacadmin1234"
 
-// NOTE: Replace the secret with a strong random key in production
-var store = sessions.NewCookieStore([]byte("super-secret-key-please-change"))
+// store is built from SESSION_STORE at startup; see newSessionStore.
+var store sessions.Store
 
 // ---------- MAIN ----------
 func main() {
-	// timezone
-	loc, _ := time.LoadLocation("Asia/Manila")
-	time.Local = loc
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	checkLogo()
 
 	// create dirs
 	if err := os.MkdirAll(qrDir, 0o755); err != nil {
@@ -59,10 +74,19 @@ func main() {
 
 	// open DB
 	var err error
-	db, err = sql.Open("sqlite", "file:data/dtr.db?_pragma=busy_timeout(5000)")
+	db, err = sql.Open("sqlite", sqliteDSN("data/dtr.db", sqliteBusyTimeout))
 	if err != nil {
 		log.Fatal(err)
 	}
+	var journal string
+	var busy int
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journal); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&busy); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("sqlite journal_mode=%s busy_timeout=%dms", journal, busy)
 	if err := initSchema(); err != nil {
 		log.Fatal(err)
 	}
@@ -72,49 +96,152 @@ func main() {
 	tplPayroll = mustTemplate("tmpl/payroll.html")
 	// login template should exist at tmpl/login.html (use the login template you added)
 	tplLogin = mustTemplate("tmpl/login.html")
+	tplDay = mustTemplate("tmpl/day.html")
+	tplAudit = mustTemplate("tmpl/audit.html")
+	tplCard = mustTemplate("tmpl/card.html")
+	tplBoard = mustTemplate("tmpl/board.html")
+
+	pdfSlots = make(chan struct{}, maxPDFRenders)
 
 	// session options
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   60 * 60, // 1 hour
-		HttpOnly: true,
+	store, err = newSessionStore(sessionOptions())
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// routes
 	http.HandleFunc("/login", handleLoginPage)
 	http.HandleFunc("/logout", handleLogout)
+	http.HandleFunc("/account/password", requireLogin(handleChangePassword))
+	http.HandleFunc("/api/session-ttl", handleSessionTTL)
+	http.HandleFunc("/api/session-keepalive", handleSessionKeepalive)
 
-	// Admin-protected routes
+	// Protected routes: any signed-in account can view...
 	http.HandleFunc("/", requireLogin(handleHome))
-	http.HandleFunc("/faculty/add", requireLogin(handleFacultyAdd))
-	http.HandleFunc("/faculty/toggle", requireLogin(handleFacultyToggle))
-	http.HandleFunc("/faculty/delete", requireLogin(handleFacultyDelete))
-	http.HandleFunc("/print-qrs.pdf", requireLogin(handlePrintQRCards))
+	http.HandleFunc("/print-qrs.pdf", requireLogin(limitPDF(handlePrintQRCards)))
+	http.HandleFunc("/print-qrs.zip", requireLogin(limitPDF(handlePrintQRZip)))
+	http.HandleFunc("/qrs.zip", requireLogin(handleQRZip))
+	http.HandleFunc("/faculty/payloads.csv", requireLogin(handleQRPayloadsCSV))
 	http.HandleFunc("/payroll", requireLogin(handlePayroll))
 	http.HandleFunc("/payroll.csv", requireLogin(handlePayrollCSV))
+	http.HandleFunc("/payroll-detail.csv", requireLogin(handlePayrollDetailCSV))
+	http.HandleFunc("/payroll/validate", requireLogin(handlePayrollValidate))
+	http.HandleFunc("/report/day", requireLogin(handleDayReport))
+	http.HandleFunc("/report/day.csv", requireLogin(handleDayReportCSV))
+	http.HandleFunc("/report/stale-open", requireLogin(handleStaleOpen))
+	http.HandleFunc("/report/not-yet-in", requireLogin(handleNotYetIn))
+	http.HandleFunc("/report/short-rest", requireLogin(handleShortRest))
+	http.HandleFunc("/api/recent-scans", requireLogin(handleRecentScans))
+	http.HandleFunc("/api/roles", requireLogin(handleRoles))
+	http.HandleFunc("/api/faculty/", requireLogin(handleFacultyAPI))
+	http.HandleFunc("/timesheet.pdf", requireLogin(limitPDF(handleTimesheetPDF)))
+	http.HandleFunc("/faculty/card-preview", requireLogin(handleCardPreview))
+	http.HandleFunc("/faculty/rate-preview", requireLogin(handleRatePreview))
+
+	// ...but only admins can change data
+	http.HandleFunc("/faculty/add", requireAdmin(handleFacultyAdd))
+	http.HandleFunc("/faculty/import", requireAdmin(handleFacultyImport))
+	http.HandleFunc("/faculty/duplicate", requireAdmin(handleFacultyDuplicate))
+	http.HandleFunc("/faculty/toggle", requireAdmin(handleFacultyToggle))
+	http.HandleFunc("/faculty/bulk-rate", requireAdmin(handleFacultyBulkRate))
+	http.HandleFunc("/faculty/schedule", requireAdmin(handleFacultySchedule))
+	http.HandleFunc("/faculty/notes", requireAdmin(handleFacultyNotes))
+	http.HandleFunc("/faculty/delete", requireAdmin(handleFacultyDelete))
+	http.HandleFunc("/dtr/save", requireAdmin(handleDTRSave))
+	http.HandleFunc("/dtr/approve-overtime", requireAdmin(handleOvertimeApprove))
+	http.HandleFunc("/periods/create", requireAdmin(handlePeriodCreate))
+	http.HandleFunc("/periods/lock", requireAdmin(handlePeriodTransition("locked")))
+	http.HandleFunc("/periods/unlock", requireAdmin(handlePeriodTransition("open")))
+	http.HandleFunc("/periods/paid", requireAdmin(handlePeriodTransition("paid")))
+	http.HandleFunc("/holidays/import", requireAdmin(handleHolidaysImport))
+	http.HandleFunc("/admin/users", requireAdmin(handleAdminUserAdd))
+	http.HandleFunc("/admin/audit", requireAdmin(handleAuditLog))
+	http.HandleFunc("/admin/audit.csv", requireAdmin(handleAuditCSV))
+	http.HandleFunc("/admin/test-email", requireAdmin(handleTestEmail))
+	http.HandleFunc("/admin/purge-dtr", requireAdmin(handlePurgeDTR))
+	http.HandleFunc("/admin/merge-sessions", requireAdmin(handleMergeSessions))
+	http.HandleFunc("/admin/cleanup-qrs", requireAdmin(handleCleanupQRs))
+	http.HandleFunc("/admin/qr/regenerate-all", requireAdmin(handleRegenerateAllQRs))
+	http.HandleFunc("/admin/qr/fix-base-url", requireAdmin(handleFixQRBase))
+	http.HandleFunc("/admin/missing-qrs", requireAdmin(handleMissingQRs))
+	http.HandleFunc("/admin/qr/regenerate-missing", requireAdmin(handleRegenerateMissingQRs))
+	http.HandleFunc("/admin/version", requireAdmin(handleVersion))
+	http.HandleFunc("/admin/logout-all", requireAdmin(handleLogoutAll))
+	http.HandleFunc("/admin/logo", requireAdmin(handleLogoUpload))
 
 	// Public/scan resources
 	http.HandleFunc("/scan/", handleScan)
+	http.HandleFunc("/scan", handleScanSubmit)
 	http.HandleFunc("/qrs/", handleQRFile)
+	http.HandleFunc("/api/verify/", handleVerifyToken)
+	http.HandleFunc("/logo", handleLogo)
+	http.HandleFunc("/board", handleBoard)
 	// serve logo / images from img/ directory
 	http.Handle("/img/", http.StripPrefix("/img/", http.FileServer(http.Dir("img/"))))
 
+	startDailySummaryJob()
+	checkQRBase()
+
 	log.Println("✅ Server running at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Fatal(http.ListenAndServe(":8080", logRequests(recoverPanics(http.DefaultServeMux))))
+}
+
+// branding is embedded in every page's data so templates can show the
+// deployment's identity as {{.AppTitle}} and {{.SchoolName}}.
+type branding struct {
+	AppTitle   string
+	SchoolName string
+}
+
+// pageBranding returns the configured branding for a page's data.
+func pageBranding() branding {
+	return branding{AppTitle: appTitle, SchoolName: schoolName}
+}
+
+// tplFuncs are helpers available to every template.
+var tplFuncs = template.FuncMap{
+	"formatDuration":  formatDuration,
+	"formatMoney":     formatMoney,
+	"weekdays":        func() []time.Weekday { return weekdays },
+	"worksOn":         worksOn,
+	"defaultWorkDays": func() int { return defaultWorkDays },
+	"scheduleLabel":   scheduleLabel,
+}
+
+// sqliteDSN builds a DSN whose pragmas the driver applies to every new
+// connection: WAL lets scans read while another writes, and busy_timeout
+// makes writers wait for the lock instead of failing immediately.
+func sqliteDSN(path string, busyTimeoutMS int) string {
+	return fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)", path, busyTimeoutMS)
 }
 
 func mustTemplate(name string) *template.Template {
-	b, err := tplFS.ReadFile(name)
+	b, err := readTemplate(name)
 	if err != nil {
 		log.Fatal(err)
 	}
-	tpl, err := template.New(filepath.Base(name)).Parse(string(b))
+	tpl, err := template.New(filepath.Base(name)).Funcs(tplFuncs).Parse(string(b))
 	if err != nil {
 		log.Fatal(err)
 	}
 	return tpl
 }
 
+// readTemplate prefers an override in templateDir and falls back to the embedded file.
+func readTemplate(name string) ([]byte, error) {
+	if templateDir != "" {
+		b, err := os.ReadFile(filepath.Join(templateDir, filepath.Base(name)))
+		if err == nil {
+			log.Printf("using template override %s", filepath.Join(templateDir, filepath.Base(name)))
+			return b, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return tplFS.ReadFile(name)
+}
+
 // ---------- DB INIT ----------
 func initSchema() error {
 	schema := `
@@ -126,27 +253,272 @@ func initSchema() error {
 		active INTEGER DEFAULT 1,
 		token TEXT UNIQUE
 	);
+	CREATE TABLE IF NOT EXISTS admins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT UNIQUE,
+		password_hash TEXT,
+		role TEXT DEFAULT 'admin'
+	);
 	CREATE TABLE IF NOT EXISTS dtr (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		faculty_id INTEGER,
 		in_time DATETIME,
 		out_time DATETIME
 	);
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		at DATETIME,
+		actor TEXT,
+		action TEXT,
+		target TEXT,
+		detail TEXT
+	);
+	CREATE TABLE IF NOT EXISTS holidays (
+		date TEXT PRIMARY KEY,
+		type TEXT,
+		multiplier REAL
+	);
+	CREATE TABLE IF NOT EXISTS locked_periods (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		start_date TEXT,
+		end_date TEXT
+	);
+	CREATE TABLE IF NOT EXISTS breaks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		dtr_id INTEGER,
+		start_time DATETIME,
+		end_time DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_breaks_dtr ON breaks(dtr_id);
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		data TEXT,
+		expires DATETIME
+	);
 	`
-	_, err := db.Exec(schema)
-	return err
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	if err := migrate(); err != nil {
+		return err
+	}
+	return seedAdmin()
+}
+
+// migrations alter existing databases; each runs once, in order, and its
+// 1-based position is recorded in schema_migrations. Only ever append.
+var migrations = []string{
+	// 1: weekly schedule as a bitmask of time.Weekday (default Mon–Fri)
+	`ALTER TABLE faculty ADD COLUMN work_days INTEGER DEFAULT 62`,
+	// 2: free-form admin notes, e.g. "on leave until May"
+	`ALTER TABLE faculty ADD COLUMN notes TEXT DEFAULT ''`,
+	// 3: which kiosk/scanner recorded the clock-in, for troubleshooting
+	`ALTER TABLE dtr ADD COLUMN device_id TEXT DEFAULT ''`,
+	// 4: which kiosk/scanner recorded the clock-out
+	`ALTER TABLE dtr ADD COLUMN out_device_id TEXT DEFAULT ''`,
+	// 5: "hourly" is paid hours × rate; "salaried" a fixed monthly amount
+	`ALTER TABLE faculty ADD COLUMN employment_type TEXT DEFAULT 'hourly'`,
+	// 6: the fixed monthly amount for salaried faculty
+	`ALTER TABLE faculty ADD COLUMN monthly_salary REAL DEFAULT 0`,
+	// 7: pay period workflow; periods created before it were all locked
+	`ALTER TABLE locked_periods ADD COLUMN status TEXT DEFAULT 'locked'`,
+	// 8: per-faculty session lookups (timesheets, the scan toggle, payroll
+	// for one faculty) seek on faculty then clock-in time
+	`CREATE INDEX IF NOT EXISTS idx_dtr_faculty_in ON dtr(faculty_id, in_time)`,
+	// 9: the all-faculty payroll and report ranges filter on clock-in time alone
+	`CREATE INDEX IF NOT EXISTS idx_dtr_in ON dtr(in_time)`,
+	// 10: the scheme and host each faculty's QR currently points at
+	`ALTER TABLE faculty ADD COLUMN qr_base TEXT DEFAULT ''`,
+	// 11: scheduled end of the working day as HH:MM, blank for none
+	`ALTER TABLE faculty ADD COLUMN shift_end TEXT DEFAULT ''`,
+	// 12: work past the shift end on this session was approved in advance
+	`ALTER TABLE dtr ADD COLUMN overtime_approved INTEGER DEFAULT 0`,
+	// 13: whether rate_per_hour is paid per "hour" worked or per "day" attended
+	`ALTER TABLE faculty ADD COLUMN rate_unit TEXT DEFAULT 'hour'`,
+	// 14: per-faculty scan cooldown in seconds; NULL uses the kiosk or global one
+	`ALTER TABLE faculty ADD COLUMN scan_cooldown INTEGER`,
+	// 15: per-faculty daily hours cap enforced at clock-in; NULL uses the global one
+	`ALTER TABLE faculty ADD COLUMN max_daily_hours REAL`,
+}
+
+func migrate() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME
+	)`)
+	if err != nil {
+		return err
+	}
+	var current int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+	for v := current + 1; v <= len(migrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", v, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", v, appNow()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("applied migration %d", v)
+	}
+	return nil
+}
+
+// buildVersion is the release this binary was built from, set at build time
+// with -ldflags "-X main.buildVersion=v1.4.0". Without it the VCS revision Go
+// stamps into the binary is reported instead.
+var buildVersion = ""
+
+func appVersion() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "dev"
+}
+
+// handleVersion reports the build, the schema migration the database is at
+// and the latest one this build knows, so a deployment can be checked for
+// migrations it didn't run.
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	var applied int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&applied); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":          appVersion(),
+		"schema_version":   applied,
+		"latest_migration": len(migrations),
+	})
+}
+
+// ---------- PDF LIMIT ----------
+
+// pdfSlots holds one token per PDF being rendered; gofpdf keeps the whole
+// document (images included) in memory, so a burst of large renders could
+// exhaust it.
+var pdfSlots chan struct{}
+
+// pdfQueueWait is how long a PDF request waits for a free slot before it is
+// turned away.
+var pdfQueueWait = 15 * time.Second
+
+// limitPDF runs h once fewer than maxPDFRenders PDFs are being rendered,
+// queueing for up to pdfQueueWait and answering 503 after that.
+func limitPDF(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timer := time.NewTimer(pdfQueueWait)
+		defer timer.Stop()
+		select {
+		case pdfSlots <- struct{}{}:
+			defer func() { <-pdfSlots }()
+			h(w, r)
+		case <-timer.C:
+			logf(r, "PDF render queue full (%d running)", maxPDFRenders)
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "The server is busy generating other PDFs; please try again shortly.", http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// ---------- RECOVERY MIDDLEWARE ----------
+
+// recoverPanics turns a panicking handler into a 500 response so one bad
+// request (a scan, a payroll run) can't take the whole server down.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			logf(r, "panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ---------- REQUEST LOGGING ----------
+
+type ctxKey int
+
+const requestIDKey ctxKey = iota
+
+// requestID returns the id logRequests assigned to r, or "-" outside a request.
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey).(string); ok {
+		return id
+	}
+	return "-"
+}
+
+// logf logs a line tagged with the request's id so every line of one request
+// (access log, panics, handler messages) can be correlated.
+func logf(r *http.Request, format string, args ...any) {
+	log.Printf("[%s] "+format, append([]any{requestID(r)}, args...)...)
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// logRequests gives each request an id, echoed in X-Request-ID, and writes
+// an access log line once it completes.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := randToken()
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		logf(r, "%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
 }
 
 // ---------- AUTH MIDDLEWARE ----------
 func requireLogin(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session, _ := store.Get(r, "session")
-		if auth, ok := session.Values["authenticated"].(bool); !ok || !auth {
+		if auth, ok := session.Values["authenticated"].(bool); !ok || !auth || sessionRemaining(session) <= 0 {
 			// if request is already to /login, let it pass
 			if r.URL.Path == "/login" {
 				next.ServeHTTP(w, r)
 				return
 			}
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeJSONError(w, http.StatusUnauthorized, "Sign in required")
+				return
+			}
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
@@ -155,25 +527,48 @@ func requireLogin(next http.HandlerFunc) http.HandlerFunc {
 }
 
 // ---------- HANDLERS ----------
+// loginPage is the login template's data on every render; Error is empty
+// unless a sign-in just failed.
+type loginPage struct {
+	branding
+	Error string
+}
+
+// renderLogin renders the login page into a buffer first, so a broken
+// template override yields a clean 500 instead of half a page.
+func renderLogin(w http.ResponseWriter, r *http.Request, errMsg string) {
+	var buf bytes.Buffer
+	if err := tplLogin.Execute(&buf, loginPage{branding: pageBranding(), Error: errMsg}); err != nil {
+		logf(r, "login template: %v", err)
+		http.Error(w, "Login page unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
 // GET/POST login page (uses embedded tmpl/login.html)
 func handleLoginPage(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		_ = tplLogin.Execute(w, nil)
+		renderLogin(w, r, "")
 		return
 	}
 	if r.Method == http.MethodPost {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
 
-		if username == adminUser && password == adminPass {
+		if role, ok := authenticate(username, password); ok {
 			session, _ := store.Get(r, "session")
 			session.Values["authenticated"] = true
+			session.Values["username"] = username
+			session.Values["role"] = role
+			session.Values["expires"] = appNow().Add(sessionTTL).Unix()
 			_ = session.Save(r, w)
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
 		}
 		// show login with error
-		_ = tplLogin.Execute(w, map[string]string{"Error": "Invalid username or password"})
+		renderLogin(w, r, "Invalid username or password")
 		return
 	}
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -183,12 +578,38 @@ func handleLoginPage(w http.ResponseWriter, r *http.Request) {
 func handleLogout(w http.ResponseWriter, r *http.Request) {
 	session, _ := store.Get(r, "session")
 	session.Values["authenticated"] = false
+	// deletes the cookie, and the server-side record with a server store
+	session.Options.MaxAge = -1
 	_ = session.Save(r, w)
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
+// facultySortColumns maps the home page's sort keys to SQL columns; only these
+// ever reach the ORDER BY clause.
+var facultySortColumns = map[string]string{
+	"id":     "id",
+	"name":   "name COLLATE NOCASE",
+	"role":   "role COLLATE NOCASE",
+	"rate":   "rate_per_hour",
+	"active": "active",
+}
+
+// facultyOrderBy builds a safe ORDER BY from the sort/dir query params,
+// falling back to id ascending for unknown keys.
+func facultyOrderBy(sortKey, dir string) (string, string, string) {
+	col, ok := facultySortColumns[sortKey]
+	if !ok {
+		sortKey, col = "id", "id"
+	}
+	if dir != "desc" {
+		dir = "asc"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id ASC", col, strings.ToUpper(dir)), sortKey, dir
+}
+
 func handleHome(w http.ResponseWriter, r *http.Request) {
-	rows, _ := db.Query("SELECT id,name,role,rate_per_hour,active,token FROM faculty")
+	orderBy, sortKey, dir := facultyOrderBy(r.FormValue("sort"), r.FormValue("dir"))
+	rows, _ := db.Query("SELECT id,name,role,rate_per_hour,active,token,work_days,notes,employment_type,monthly_salary,shift_end,rate_unit,scan_cooldown,max_daily_hours FROM faculty" + orderBy)
 	defer rows.Close()
 
 	type Faculty struct {
@@ -198,20 +619,67 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		RatePerHour float64
 		Active      bool
 		Token       string
+		WorkDays    int
+		Notes       string
+		EmpType     string
+		Salary      float64
+		ShiftEnd    string
+		RateUnit    string
+		Cooldown    sql.NullInt64
+		MaxHours    sql.NullFloat64
 	}
 	var faculty []Faculty
 	for rows.Next() {
 		var f Faculty
-		rows.Scan(&f.ID, &f.Name, &f.Role, &f.RatePerHour, &f.Active, &f.Token)
+		rows.Scan(&f.ID, &f.Name, &f.Role, &f.RatePerHour, &f.Active, &f.Token, &f.WorkDays, &f.Notes, &f.EmpType, &f.Salary, &f.ShiftEnd, &f.RateUnit, &f.Cooldown, &f.MaxHours)
 		faculty = append(faculty, f)
 	}
 
+	periods, _ := listPayPeriods()
+	current, _ := currentPeriod()
+	stale, _ := staleQRBases()
+	missing, _ := missingQRFiles()
+
 	data := struct {
-		Today   string
-		Faculty []Faculty
+		branding
+		Today    string
+		Timezone string
+		Flash    string
+		IsAdmin  bool
+		Sort     string
+		Dir      string
+		Faculty  []Faculty
+		Periods  []payPeriod
+		Current  *payPeriod
+
+		RetentionDays int
+		SessionWarn   int
+		QRStale       int
+		QRMissing     int
+		BaseURL       string
+		MergeGap      time.Duration
+		RoleRates     map[string]float64
+		ServerStore   bool
 	}{
-		Today:   time.Now().Format("2006-01-02"),
-		Faculty: faculty,
+		branding: pageBranding(),
+		Today:    appNow().Format("2006-01-02"),
+		Timezone: appLoc.String(),
+		Flash:    getFlash(w, r),
+		IsAdmin:  sessionRole(r) == roleAdmin,
+		Sort:     sortKey,
+		Dir:      dir,
+		Faculty:  faculty,
+		Periods:  periods,
+		Current:  current,
+
+		RetentionDays: retentionDays,
+		SessionWarn:   int(sessionWarn / time.Second),
+		QRStale:       len(stale),
+		QRMissing:     len(missing),
+		BaseURL:       baseURL,
+		MergeGap:      mergeGap,
+		RoleRates:     roleDefaultRates,
+		ServerStore:   sessionStore != "cookie",
 	}
 
 	tplIndex.Execute(w, data)
@@ -223,38 +691,194 @@ func handleFacultyAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	name := r.FormValue("name")
-	role := r.FormValue("role")
-	rateStr := r.FormValue("rate")
-	rate, _ := strconv.ParseFloat(rateStr, 64)
+	role, err := checkRole(r.FormValue("role"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rateStr := strings.TrimSpace(r.FormValue("rate"))
+	var rate float64
+	if rateStr == "" {
+		var ok bool
+		if rate, ok = roleDefaultRates[normalizeRole(role)]; !ok {
+			http.Error(w, fmt.Sprintf("Rate is required: role %q has no default rate", role), http.StatusBadRequest)
+			return
+		}
+	} else if rate, err = strconv.ParseFloat(rateStr, 64); err != nil || rate < 0 {
+		http.Error(w, "Invalid rate per hour", http.StatusBadRequest)
+		return
+	}
 
-	token := randToken()
-	_, err := db.Exec("INSERT INTO faculty (name,role,rate_per_hour,token) VALUES (?,?,?,?)",
-		name, role, rate, token)
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	if len(notes) > maxNotesLen {
+		http.Error(w, fmt.Sprintf("Notes are limited to %d characters", maxNotesLen), http.StatusBadRequest)
+		return
+	}
+
+	empType := r.FormValue("employment_type")
+	if empType == "" {
+		empType = "hourly"
+	}
+	if !employmentTypes[empType] {
+		http.Error(w, "Invalid employment type (want hourly or salaried)", http.StatusBadRequest)
+		return
+	}
+	rateUnit := r.FormValue("rate_unit")
+	if rateUnit == "" {
+		rateUnit = "hour"
+	}
+	if !rateUnits[rateUnit] {
+		http.Error(w, "Invalid rate unit (want hour or day)", http.StatusBadRequest)
+		return
+	}
+	var salary float64
+	if empType == "salaried" {
+		salary, err = strconv.ParseFloat(r.FormValue("monthly_salary"), 64)
+		if err != nil || salary <= 0 {
+			http.Error(w, "Salaried faculty need a positive monthly salary", http.StatusBadRequest)
+			return
+		}
+	}
+
+	workDays := defaultWorkDays
+	if r.Form["work_days"] != nil {
+		workDays = parseWorkDays(r.Form["work_days"])
+	}
+
+	// a custom token lets already-printed cards keep working after a migration
+	token := strings.TrimSpace(r.FormValue("token"))
+	if token == "" {
+		token = randToken()
+	} else if !tokenPattern.MatchString(token) {
+		http.Error(w, "Invalid token: use 4-64 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	} else {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM faculty WHERE token=?", token).Scan(&n); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if n > 0 {
+			http.Error(w, "Token already in use", http.StatusConflict)
+			return
+		}
+	}
+
+	// insert first so a failed insert never leaves an orphan QR on disk
+	qrMu.Lock()
+	defer qrMu.Unlock()
+	res, err := db.Exec("INSERT INTO faculty (name,role,rate_per_hour,token,work_days,notes,employment_type,monthly_salary,rate_unit) VALUES (?,?,?,?,?,?,?,?,?)",
+		name, role, rate, token, workDays, notes, empType, salary, rateUnit)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 
-	// generate QR with scan URL + readable info
-	payload := fmt.Sprintf("http://%s/scan/%s\nName: %s\nRole: %s", r.Host, token, name, role)
-	qrFile := filepath.Join(qrDir, token+".png")
-	_ = qrcode.WriteFile(payload, qrcode.Medium, 256, qrFile)
+	if err := writeQR(r.Host, token, name, role); err != nil {
+		// roll back the row so the registry never lists a faculty without a card
+		if id, idErr := res.LastInsertId(); idErr == nil {
+			_, _ = db.Exec("DELETE FROM faculty WHERE id=?", id)
+		}
+		http.Error(w, "Failed to generate QR: "+err.Error(), 500)
+		return
+	}
 
+	audit(r, "faculty.add", token, name)
+	setFlash(w, r, fmt.Sprintf("Added %s.", name))
 	http.Redirect(w, r, "/", 302)
 }
 
-func handleFacultyToggle(w http.ResponseWriter, r *http.Request) {
-	// Accept id from POST form value instead of URL query
+// maxNotesLen bounds a faculty's notes.
+const maxNotesLen = 1000
+
+// handleFacultyNotes replaces a faculty's notes; an empty value clears them.
+func handleFacultyNotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
 	id := r.FormValue("id")
 	if id == "" {
 		http.Error(w, "Missing id", http.StatusBadRequest)
 		return
 	}
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	if len(notes) > maxNotesLen {
+		http.Error(w, fmt.Sprintf("Notes are limited to %d characters", maxNotesLen), http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec("UPDATE faculty SET notes=? WHERE id=?", notes, id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Faculty not found", http.StatusNotFound)
+		return
+	}
+	audit(r, "faculty.notes", "faculty "+id, notes)
+	setFlash(w, r, "Notes updated.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleFacultyDuplicate clones a faculty's role and pay terms under a placeholder
+// name, with a fresh token and QR, as a starting point for similar staff.
+func handleFacultyDuplicate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+
+	var srcName, role, empType, rateUnit string
+	var rate, salary float64
+	var workDays int
+	err := db.QueryRow("SELECT name,role,rate_per_hour,work_days,employment_type,monthly_salary,rate_unit FROM faculty WHERE id=?", id).
+		Scan(&srcName, &role, &rate, &workDays, &empType, &salary, &rateUnit)
+	if err != nil {
+		http.Error(w, "Faculty not found", http.StatusNotFound)
+		return
+	}
+
+	name := "Copy of " + srcName
+	qrMu.Lock()
+	defer qrMu.Unlock()
+	token := randToken()
+	res, err := db.Exec("INSERT INTO faculty (name,role,rate_per_hour,token,work_days,employment_type,monthly_salary,rate_unit) VALUES (?,?,?,?,?,?,?,?)",
+		name, role, rate, token, workDays, empType, salary, rateUnit)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if err := writeQR(r.Host, token, name, role); err != nil {
+		if newID, idErr := res.LastInsertId(); idErr == nil {
+			_, _ = db.Exec("DELETE FROM faculty WHERE id=?", newID)
+		}
+		http.Error(w, "Failed to generate QR: "+err.Error(), 500)
+		return
+	}
+
+	audit(r, "faculty.duplicate", token, "from faculty "+id)
+	setFlash(w, r, fmt.Sprintf("Created %s; rename it before printing cards.", name))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func handleFacultyToggle(w http.ResponseWriter, r *http.Request) {
+	// Accept id from POST form value instead of URL query
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Missing or invalid id")
+		return
+	}
 
 	// Flip active flag
-	_, err := db.Exec("UPDATE faculty SET active=1-active WHERE id=?", id)
+	_, err = db.Exec("UPDATE faculty SET active=1-active WHERE id=?", id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -262,13 +886,103 @@ func handleFacultyToggle(w http.ResponseWriter, r *http.Request) {
 	var active int
 	err = db.QueryRow("SELECT active FROM faculty WHERE id=?", id).Scan(&active)
 	if err != nil {
-		http.Error(w, "Faculty not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Faculty not found")
 		return
 	}
 
-	// Return JSON result
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"id":%s,"active":%t}`, id, active == 1)
+	audit(r, "faculty.toggle", fmt.Sprintf("faculty %d", id), fmt.Sprintf("active=%t", active == 1))
+
+	writeJSON(w, http.StatusOK, struct {
+		ID     int  `json:"id"`
+		Active bool `json:"active"`
+	}{id, active == 1})
+}
+
+// handleFacultyBulkRate sets the rate of every hourly or daily faculty in a
+// role, either to a new rate or by a percentage (e.g. percent=5 for a 5%
+// raise), in one transaction. Salaried faculty aren't paid by rate and are
+// left alone. There is no rate history; the audit log records the change.
+func handleFacultyBulkRate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	role := normalizeRole(r.FormValue("role"))
+	if role == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing role")
+		return
+	}
+	rateStr, pctStr := r.FormValue("rate"), r.FormValue("percent")
+	if (rateStr == "") == (pctStr == "") {
+		writeJSONError(w, http.StatusBadRequest, "Give exactly one of rate or percent")
+		return
+	}
+	var newRate func(old float64) float64
+	var detail string
+	if rateStr != "" {
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid rate")
+			return
+		}
+		newRate = func(float64) float64 { return roundMoney(rate) }
+		detail = fmt.Sprintf("rate=%.2f", rate)
+	} else {
+		pct, err := strconv.ParseFloat(pctStr, 64)
+		if err != nil || pct <= -100 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid percent")
+			return
+		}
+		newRate = func(old float64) float64 { return roundMoney(old * (1 + pct/100)) }
+		detail = fmt.Sprintf("percent=%g", pct)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	rs, err := tx.Query("SELECT id, rate_per_hour FROM faculty WHERE lower(trim(role))=? AND employment_type != 'salaried'", role)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rates := map[int]float64{}
+	for rs.Next() {
+		var id int
+		var rate float64
+		if err := rs.Scan(&id, &rate); err != nil {
+			rs.Close()
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rates[id] = rate
+	}
+	rs.Close()
+	changed := 0
+	for id, old := range rates {
+		rate := newRate(old)
+		if rate == old {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE faculty SET rate_per_hour=? WHERE id=?", rate, id); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		changed++
+	}
+	if err := tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit(r, "faculty.bulk_rate", "role "+role, fmt.Sprintf("%s, changed %d of %d", detail, changed, len(rates)))
+
+	writeJSON(w, http.StatusOK, struct {
+		Role    string `json:"role"`
+		Matched int    `json:"matched"`
+		Changed int    `json:"changed"`
+	}{role, len(rates), changed})
 }
 
 func handleFacultyDelete(w http.ResponseWriter, r *http.Request) {
@@ -280,81 +994,233 @@ func handleFacultyDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete faculty by id
+	qrMu.Lock()
+	defer qrMu.Unlock()
 	_, err := db.Exec("DELETE FROM faculty WHERE id=?", id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	audit(r, "faculty.delete", "faculty "+id, "")
+
 	// Redirect back to home page after deletion
+	setFlash(w, r, "Faculty deleted.")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// handleScan answers a QR scan with a confirmation page carrying a one-time
+// nonce; the page submits itself to POST /scan, which records the IN/OUT.
 func handleScan(w http.ResponseWriter, r *http.Request) {
+	if !clientAllowed(r.RemoteAddr, scanAllowlist) {
+		http.Error(w, "Scanning is not allowed from this network", http.StatusForbidden)
+		return
+	}
 	token := strings.TrimPrefix(r.URL.Path, "/scan/")
 
-	var fid int
 	var name, role string
-	err := db.QueryRow("SELECT id,name,role FROM faculty WHERE token=?", token).Scan(&fid, &name, &role)
+	err := db.QueryRow("SELECT name,role FROM faculty WHERE token=?", token).Scan(&name, &role)
 	if err != nil {
-		http.Error(w, "Faculty not found", 404)
+		writeUnknownScan(w, r, token)
 		return
 	}
 
-	now := time.Now()
-	var dtrID int
-	var inTime sql.NullTime
-	err = db.QueryRow("SELECT id,in_time FROM dtr WHERE faculty_id=? AND out_time IS NULL ORDER BY in_time DESC LIMIT 1", fid).Scan(&dtrID, &inTime)
-
-	status := ""
-	if err == sql.ErrNoRows {
-		// clock IN
-		_, _ = db.Exec("INSERT INTO dtr(faculty_id,in_time) VALUES (?,?)", fid, now)
-		status = "Clock IN"
-	} else if err == nil {
-		// clock OUT
-		_, _ = db.Exec("UPDATE dtr SET out_time=? WHERE id=?", now, dtrID)
-		status = "Clock OUT"
-	} else {
-		http.Error(w, "DB error", 500)
+	// kiosks with a break button open /scan/<token>?type=break-start or break-end
+	typ := r.FormValue("type")
+	if !scanTypes[typ] {
+		http.Error(w, "Invalid scan type", http.StatusBadRequest)
 		return
 	}
 
-	// show friendly HTML page
+	nonce := scanNonces.issue(token)
+	// kiosks can tag their scans by opening /scan/<token>?device_id=...
+	device := scanDeviceID(r)
+	// passed through for handleScanSubmit, which honours it only for admins
+	bypass := r.FormValue("bypass_cooldown") == "true"
+	// receipt printers open /scan/<token>?format=receipt
+	format := ""
+	if r.FormValue("format") == "receipt" {
+		format = "receipt"
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
 	fmt.Fprintf(w, `
 		<!doctype html>
 		<html><head><meta charset="utf-8"/>
-		<title>Scan Result</title></head><body>
-		<h2>%s</h2>
+		<title>Confirm Scan</title></head><body>
+		<h2>Recording scan…</h2>
 		<p><b>%s</b> (%s)</p>
-		<p>%s at %s</p>
-		<p><a href="/">← Back to Home</a></p>
+		<form id="scan" method="post" action="/scan">
+			<input type="hidden" name="token" value="%s"/>
+			<input type="hidden" name="nonce" value="%s"/>
+			<input type="hidden" name="device_id" value="%s"/>
+			<input type="hidden" name="type" value="%s"/>
+			<input type="hidden" name="bypass_cooldown" value="%t"/>
+			<input type="hidden" name="format" value="%s"/>
+			<button type="submit">Confirm</button>
+		</form>
+		<script>document.getElementById('scan').submit();</script>
 		</body></html>
-	`, status, name, role, status, now.Format("2006-01-02 15:04:05"))
+	`, template.HTMLEscapeString(name), template.HTMLEscapeString(role), token, nonce, template.HTMLEscapeString(device), typ, bypass, format)
 }
 
 func handleQRFile(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, filepath.Join(qrDir, filepath.Base(r.URL.Path)))
+	path := filepath.Join(qrDir, filepath.Base(r.URL.Path))
+
+	// QR images rarely change for a token, so let clients cache them and
+	// revalidate with an ETag; http.ServeFile answers If-None-Match with 304.
+	if fi, err := os.Stat(path); err == nil {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+	}
+	http.ServeFile(w, r, path)
+}
+
+// qrCard is one faculty's printable card.
+type qrCard struct {
+	name, role, token string
+}
+
+// qrCardLayout is how cards are laid out, from the print-qrs query params.
+type qrCardLayout struct {
+	paper       string
+	withBarcode bool
+	autoFit     bool
 }
 
+func parseQRCardLayout(r *http.Request) (qrCardLayout, error) {
+	l := qrCardLayout{paper: r.FormValue("paper")}
+	if l.paper == "" {
+		l.paper = "A4"
+	}
+	if _, ok := paperMM[l.paper]; !ok {
+		return l, errors.New("Unknown paper size (want A4, Letter or Legal)")
+	}
+	// ?barcode=1 adds a Code-128 of the token under the QR for scanners that
+	// read barcodes more reliably; the card grows to make room
+	l.withBarcode, _ = strconv.ParseBool(r.FormValue("barcode"))
+	// ?auto_fit=true fits as many full-size cards as the paper holds instead
+	l.autoFit, _ = strconv.ParseBool(r.FormValue("auto_fit"))
+	return l, nil
+}
+
+// countQRCards counts the active faculty with a card, optionally of one role.
+func countQRCards(role string) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM faculty WHERE active=1 AND (?='' OR lower(role)=lower(?))", role, role).Scan(&n)
+	return n, err
+}
+
+// loadQRCards loads one page of at most maxQRCards cards, in id order.
+func loadQRCards(role string, page int) ([]qrCard, error) {
+	rows, err := db.Query("SELECT name, role, token FROM faculty WHERE active=1 AND (?='' OR lower(role)=lower(?)) ORDER BY id LIMIT ? OFFSET ?",
+		role, role, maxQRCards, (page-1)*maxQRCards)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cards []qrCard
+	for rows.Next() {
+		var c qrCard
+		if err := rows.Scan(&c.name, &c.role, &c.token); err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+// handlePrintQRCards renders the active faculty's cards as one PDF. Rosters
+// larger than maxQRCards are printed a page of cards at a time (?page=N),
+// narrowed with ?role=, or downloaded whole from /print-qrs.zip.
 func handlePrintQRCards(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, name, role, token FROM faculty WHERE active=1")
+	layout, err := parseQRCardLayout(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	role := strings.TrimSpace(r.FormValue("role"))
+	total, err := countQRCards(role)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	defer rows.Close()
+	pages := max(1, (total+maxQRCards-1)/maxQRCards)
+	page := 1
+	if v := r.FormValue("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 || page > pages {
+			http.Error(w, fmt.Sprintf("Invalid page %q (want 1 to %d)", v, pages), http.StatusBadRequest)
+			return
+		}
+	} else if pages > 1 {
+		http.Error(w, fmt.Sprintf("%d cards is more than the %d printed at once; print them in batches with page=1 to page=%d, "+
+			"one role at a time with role=, or download every batch from /print-qrs.zip.", total, maxQRCards, pages), http.StatusBadRequest)
+		return
+	}
+	cards, err := loadQRCards(role, page)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	if err := writeQRCardsPDF(w, cards, layout); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	// NOTE: ensure fonts/Roboto-Regular.ttf exists or change font
-	pdf.AddUTF8Font("Roboto", "", "fonts/Roboto-Regular.ttf")
-	pdf.SetFont("Roboto", "", 8)
-	pdf.AddPage()
+// handlePrintQRZip renders every batch of maxQRCards cards as its own PDF in
+// a ZIP. Batches are rendered one after another, so only one is ever held in
+// memory.
+func handlePrintQRZip(w http.ResponseWriter, r *http.Request) {
+	layout, err := parseQRCardLayout(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	role := strings.TrimSpace(r.FormValue("role"))
+	total, err := countQRCards(role)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	pages := max(1, (total+maxQRCards-1)/maxQRCards)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment;filename=qr-cards.zip")
+	zw := zip.NewWriter(w)
+	for page := 1; page <= pages; page++ {
+		cards, err := loadQRCards(role, page)
+		if err != nil {
+			logf(r, "print-qrs.zip: %v", err)
+			return
+		}
+		fw, err := zw.Create(fmt.Sprintf("qr-cards-%03d.pdf", page))
+		if err != nil {
+			logf(r, "print-qrs.zip: %v", err)
+			return
+		}
+		if err := writeQRCardsPDF(fw, cards, layout); err != nil {
+			logf(r, "print-qrs.zip: %v", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logf(r, "print-qrs.zip: %v", err)
+	}
+}
+
+// writeQRCardsPDF lays the cards out on pages of the chosen paper.
+func writeQRCardsPDF(out io.Writer, cards []qrCard, layout qrCardLayout) error {
+	paper, withBarcode := layout.paper, layout.withBarcode
+	cardHeight := cardH
+	if withBarcode {
+		cardHeight += cardBarcodeH + cardLogoGap
+	}
 
 	// Card settings for 3×3 grid
-	cardW := 60.0
-	cardH := 70.0 // taller to fit QR under text
+	orientation := "P"
 	marginX := 10.0
 	marginY := 10.0
 	spacingX := 8.0
@@ -362,32 +1228,60 @@ func handlePrintQRCards(w http.ResponseWriter, r *http.Request) {
 	cardsPerRow := 3
 	cardsPerCol := 3
 
+	if layout.autoFit {
+		g := fitCardGrid(paper, cardW, cardHeight)
+		orientation, cardsPerRow, cardsPerCol = g.Orientation, g.Cols, g.Rows
+		marginX, marginY, spacingX, spacingY = g.MarginX, g.MarginY, g.SpacingX, g.SpacingY
+	}
+
+	pdf := gofpdf.New(orientation, "mm", paper, "")
+	// NOTE: ensure fonts/Roboto-Regular.ttf exists or change font
+	pdf.AddUTF8Font("Roboto", "", "fonts/Roboto-Regular.ttf")
+	pdf.SetFont("Roboto", "", 8)
+	pdf.AddPage()
+
 	x := marginX
 	y := marginY
 	col := 0
 	row := 0
 
-	for rows.Next() {
-		var id int
-		var name, role, token string
-		rows.Scan(&id, &name, &role, &token)
+	for _, c := range cards {
+		name, role, token := c.name, c.role, c.token
+
+		// start a new page only when a card needs one, so a full last page
+		// isn't followed by a blank one
+		if row >= cardsPerCol {
+			pdf.AddPage()
+			x = marginX
+			y = marginY
+			row = 0
+		}
 
 		// Draw card border
 		pdf.SetDrawColor(0, 0, 0)
-		pdf.Rect(x, y, cardW, cardH, "D")
+		pdf.Rect(x, y, cardW, cardHeight, "D")
 
 		// Faculty info (centered horizontally)
-		pdf.SetXY(x, y+8)
+		pdf.SetXY(x, y+cardNameY)
 		pdf.CellFormat(cardW, 5, name, "", 0, "C", false, 0, "")
-		pdf.SetXY(x, y+14)
+		pdf.SetXY(x, y+cardRoleY)
 		pdf.CellFormat(cardW, 5, fmt.Sprintf("(%s)", role), "", 0, "C", false, 0, "")
 
 		// QR code (centered under role)
-		qrPath := filepath.Join(qrDir, token+".png")
-		qrSize := 28.0
-		qrX := x + (cardW-qrSize)/2
-		qrY := y + 25
-		pdf.ImageOptions(qrPath, qrX, qrY, qrSize, qrSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		qrPath := qrFile(token)
+		qrX := x + (cardW-cardQRSize)/2
+		qrY := y + cardQRY
+		pdf.ImageOptions(qrPath, qrX, qrY, cardQRSize, cardQRSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		logoY := qrY + cardQRSize + cardLogoGap
+		if withBarcode {
+			key := barcode.RegisterCode128(pdf, token)
+			barcode.Barcode(pdf, key, x+(cardW-cardBarcodeW)/2, logoY, cardBarcodeW, cardBarcodeH, false)
+			logoY += cardBarcodeH + cardLogoGap
+		}
+
+		// School logo (centered under QR)
+		drawLogo(pdf, x+(cardW-logoWidth(pdf, logoHeightMM))/2, logoY, logoHeightMM)
 
 		// Move grid
 		col++
@@ -395,158 +1289,257 @@ func handlePrintQRCards(w http.ResponseWriter, r *http.Request) {
 			col = 0
 			row++
 			x = marginX
-			y += cardH + spacingY
-			if row >= cardsPerCol {
-				pdf.AddPage()
-				x = marginX
-				y = marginY
-				row = 0
-			}
+			y += cardHeight + spacingY
 		} else {
 			x += cardW + spacingX
 		}
 	}
 
-	// Output PDF
-	w.Header().Set("Content-Type", "application/pdf")
-	if err := pdf.Output(w); err != nil {
-		http.Error(w, err.Error(), 500)
-	}
+	return pdf.Output(out)
 }
 
 func handlePayroll(w http.ResponseWriter, r *http.Request) {
-	start, _ := time.Parse("2006-01-02", r.FormValue("start"))
-	end, _ := time.Parse("2006-01-02", r.FormValue("end"))
-	if end.IsZero() {
-		end = time.Now()
+	type payrollPage struct {
+		branding
+		Start, End string
+		Error      string
+		Rows       []payrollRow
+		GrandTotal float64
+		Flagged    int
 	}
 
-	type Row struct {
-		FacultyID   int
-		Name        string
-		Role        string
-		RatePerHour float64
-		TotalHours  float64
-		Pay         float64
-	}
-	var rows []Row
-	var grand float64
-
-	q := `
-	SELECT f.id, f.name, f.role, f.rate_per_hour, d.in_time, d.out_time
-	FROM faculty f
-	LEFT JOIN dtr d 
-	  ON d.faculty_id = f.id
-	  AND d.in_time BETWEEN ? AND ?
-	`
-	rs, err := db.Query(q, start, end)
+	start, end, err := parsePayrollRange(r)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		// show the form again with the problem instead of a nonsensical report
+		w.WriteHeader(http.StatusBadRequest)
+		tplPayroll.Execute(w, payrollPage{
+			branding: pageBranding(),
+			Start:    r.FormValue("start"),
+			End:      r.FormValue("end"),
+			Error:    err.Error(),
+		})
 		return
 	}
-	defer rs.Close()
 
-	m := map[int]*Row{}
-
-	for rs.Next() {
-		var id int
-		var name, role string
-		var rate float64
-		var inT, outT sql.NullTime
-		if err := rs.Scan(&id, &name, &role, &rate, &inT, &outT); err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
-		if _, ok := m[id]; !ok {
-			m[id] = &Row{FacultyID: id, Name: name, Role: role, RatePerHour: rate}
-		}
-		if inT.Valid && outT.Valid {
-			dur := outT.Time.Sub(inT.Time).Hours()
-			if dur > 0 {
-				m[id].TotalHours += dur
-			}
-		}
+	rows, _, err := computePayroll(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
-
-	for _, r := range m {
-		r.TotalHours = math.Round(r.TotalHours*4) / 4
-		r.Pay = r.TotalHours * r.RatePerHour
-		grand += r.Pay
-		rows = append(rows, *r)
+	grand := presentPay(rows)
+	var flagged int
+	for _, row := range rows {
+		if row.Flagged {
+			flagged++
+		}
 	}
 
-	data := struct {
-		Start, End string
-		Rows       []Row
-		GrandTotal float64
-	}{
+	tplPayroll.Execute(w, payrollPage{
+		branding:   pageBranding(),
 		Start:      start.Format("2006-01-02"),
 		End:        end.Format("2006-01-02"),
 		Rows:       rows,
 		GrandTotal: grand,
-	}
+		Flagged:    flagged,
+	})
+}
 
-	tplPayroll.Execute(w, data)
+// parsePayrollRange reads the inclusive start/end days of a payroll request.
+// Empty values default to the current pay period, or without one to the
+// first of the current month and today.
+func parsePayrollRange(r *http.Request) (start, end time.Time, err error) {
+	today := dayStart(appNow())
+	start = today.AddDate(0, 0, 1-today.Day())
+	end = today
+	if p, perr := currentPeriod(); perr == nil && p != nil {
+		start, _ = time.ParseInLocation("2006-01-02", p.Start, appLoc)
+		end, _ = time.ParseInLocation("2006-01-02", p.End, appLoc)
+	}
+	if s := r.FormValue("start"); s != "" {
+		if start, err = time.ParseInLocation("2006-01-02", s, appLoc); err != nil {
+			return start, end, fmt.Errorf("Invalid start date %q, expected YYYY-MM-DD", s)
+		}
+	}
+	if s := r.FormValue("end"); s != "" {
+		if end, err = time.ParseInLocation("2006-01-02", s, appLoc); err != nil {
+			return start, end, fmt.Errorf("Invalid end date %q, expected YYYY-MM-DD", s)
+		}
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("End date %s is before start date %s", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+	return start, end, nil
 }
 
 func handlePayrollCSV(w http.ResponseWriter, r *http.Request) {
-	start, _ := time.Parse("2006-01-02", r.FormValue("start"))
-	end, _ := time.Parse("2006-01-02", r.FormValue("end"))
-	if end.IsZero() {
-		end = time.Now()
+	start, end, err := parsePayrollRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, _, err := computePayroll(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
+	presentPay(rows)
 
-	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
 	w.Header().Set("Content-Disposition", "attachment;filename=payroll.csv")
+	if csvBOM {
+		w.Write([]byte("\uFEFF"))
+	}
 	csvw := csv.NewWriter(w)
+	csvw.Comma = csvDelimiter
 	defer csvw.Flush()
 
-	csvw.Write([]string{"FacultyID", "Name", "Role", "Rate/hr", "TotalHours", "Pay"})
+	csvw.Write([]string{"FacultyID", "Name", "Role", "EmploymentType", "Rate", "RateUnit", "MonthlySalary", "TotalHours", "OvertimeHours", "UnpaidOvertimeHours", "OvertimeThreshold", "HolidayHours", "DaysWorked", "NegativeSessions", "Pay"})
 
-	q := `
-	SELECT f.id,f.name,f.role,f.rate_per_hour,d.in_time,d.out_time
-	FROM faculty f
-	LEFT JOIN dtr d ON d.faculty_id=f.id
-	WHERE d.in_time BETWEEN ? AND ?
-	`
-	rs, _ := db.Query(q, start, end)
-	defer rs.Close()
-	m := map[int]struct {
-		name  string
-		role  string
-		rate  float64
-		hours float64
-	}{}
+	for _, r := range rows {
+		csvw.Write([]string{
+			strconv.Itoa(r.FacultyID), r.Name, r.Role, r.EmploymentType,
+			fmt.Sprintf("%.2f", r.RatePerHour), r.RateUnit,
+			fmt.Sprintf("%.2f", r.MonthlySalary),
+			fmt.Sprintf("%.2f", r.TotalHours),
+			fmt.Sprintf("%.2f", r.OvertimeHours),
+			fmt.Sprintf("%.2f", r.UnpaidOvertime),
+			fmt.Sprintf("%.2f", r.OvertimeThreshold),
+			fmt.Sprintf("%.2f", r.HolidayHours),
+			strconv.Itoa(r.DaysWorked),
+			strconv.Itoa(r.NegativeSessions),
+			fmt.Sprintf("%.2f", r.Pay),
+		})
+	}
+}
 
-	for rs.Next() {
-		var id int
-		var name, role string
-		var rate float64
-		var inT, outT sql.NullTime
-		rs.Scan(&id, &name, &role, &rate, &inT, &outT)
-		tmp := m[id]
-		tmp.name, tmp.role, tmp.rate = name, role, rate
-		if inT.Valid && outT.Valid {
-			tmp.hours += outT.Time.Sub(inT.Time).Hours()
+// ---------- FLASH ----------
+// setFlash stores a one-time message shown on the next rendered page.
+func setFlash(w http.ResponseWriter, r *http.Request, msg string) {
+	session, _ := store.Get(r, "session")
+	session.AddFlash(msg)
+	_ = session.Save(r, w)
+}
+
+// getFlash returns and clears any pending flash messages.
+func getFlash(w http.ResponseWriter, r *http.Request) string {
+	session, _ := store.Get(r, "session")
+	flashes := session.Flashes()
+	if len(flashes) == 0 {
+		return ""
+	}
+	_ = session.Save(r, w)
+	var msgs []string
+	for _, f := range flashes {
+		if m, ok := f.(string); ok {
+			msgs = append(msgs, m)
 		}
-		m[id] = tmp
 	}
+	return strings.Join(msgs, " ")
+}
 
-	for id, r := range m {
-		h := math.Round(r.hours*4) / 4
-		pay := h * r.rate
-		csvw.Write([]string{
-			strconv.Itoa(id), r.name, r.role,
-			fmt.Sprintf("%.2f", r.rate),
-			fmt.Sprintf("%.2f", h),
-			fmt.Sprintf("%.2f", pay),
-		})
+// ---------- UTIL ----------
+// formatDuration renders hours for display according to hoursFormat.
+func formatDuration(hours float64) string {
+	if hoursFormat == "hhmm" {
+		return hoursToHHMM(hours)
 	}
+	return fmt.Sprintf("%.2f", hours)
 }
 
-// ---------- UTIL ----------
+// hoursToHHMM converts decimal hours to h:mm, e.g. 7.75 -> "7:45".
+func hoursToHHMM(hours float64) string {
+	sign := ""
+	if hours < 0 {
+		sign = "-"
+		hours = -hours
+	}
+	mins := int(math.Round(hours * 60))
+	return fmt.Sprintf("%s%d:%02d", sign, mins/60, mins%60)
+}
+
+// qrPayload is the QR content: scan URL + readable info. The URL uses
+// baseURL when configured, otherwise the host the admin is browsing from.
+func qrPayload(host, token, name, role string) string {
+	return fmt.Sprintf("%s/scan/%s\nName: %s\nRole: %s", payloadBase(host), token, name, role)
+}
+
+// payloadBase is the scheme and host a QR written now would point at.
+func payloadBase(host string) string {
+	if baseURL != "" {
+		return baseURL
+	}
+	return "http://" + host
+}
+
+// qrFile is where a token's QR PNG lives.
+func qrFile(token string) string {
+	return filepath.Join(qrDir, token+".png")
+}
+
+// writeQR generates the faculty's QR. The PNG is written to a temp file and
+// renamed so a concurrent reader or regeneration never sees a partial image.
+func writeQR(host, token, name, role string) error {
+	q, err := qrcode.New(qrPayload(host, token, name, role), qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	q.ForegroundColor, q.BackgroundColor = qrForeground, qrBackground
+	q.DisableBorder = !qrQuietZone
+	png, err := q.PNG(256)
+	if err != nil {
+		return err
+	}
+	path := qrFile(token)
+	tmp, err := os.CreateTemp(qrDir, token+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(png); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// remembered so a later BASE_URL change can be spotted; see checkQRBase
+	_, err = db.Exec("UPDATE faculty SET qr_base=? WHERE token=?", payloadBase(host), token)
+	return err
+}
+
+// tokenPattern is the accepted form of a manually set token; it ends up in
+// scan URLs and QR file names, so it is kept to URL- and filename-safe characters.
+var tokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{4,64}$`)
+
 func randToken() string {
 	b := make([]byte, 8)
 	rand.Read(b)
 	return fmt.Sprintf("%x", b)
 }
+
+// uploadedFile opens a multipart file field, limiting the request body to
+// maxUploadBytes. On failure it writes the error response and returns false.
+func uploadedFile(w http.ResponseWriter, r *http.Request, field string) (multipart.File, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, fmt.Sprintf("Upload too large (max %d MB)", maxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Missing "+field+" upload", http.StatusBadRequest)
+		return nil, false
+	}
+	return file, true
+}
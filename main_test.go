@@ -0,0 +1,1070 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// TestMain runs the tests against a scratch database and QR directory, with
+// the templates parsed the way main does.
+func TestMain(m *testing.M) {
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	dir, err := os.MkdirTemp("", "dtr-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	qrDir = filepath.Join(dir, "qrs")
+	pdfDir = filepath.Join(dir, "pdf")
+	for _, d := range []string{qrDir, pdfDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	db, err = sql.Open("sqlite", sqliteDSN(filepath.Join(dir, "dtr.db"), sqliteBusyTimeout))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+	if err := initSchema(); err != nil {
+		log.Fatal(err)
+	}
+	tplIndex = mustTemplate("tmpl/index.html")
+	tplPayroll = mustTemplate("tmpl/payroll.html")
+	tplLogin = mustTemplate("tmpl/login.html")
+	tplDay = mustTemplate("tmpl/day.html")
+	tplAudit = mustTemplate("tmpl/audit.html")
+	tplCard = mustTemplate("tmpl/card.html")
+	tplBoard = mustTemplate("tmpl/board.html")
+	pdfSlots = make(chan struct{}, maxPDFRenders)
+	store = sessions.NewCookieStore(sessionKey)
+
+	code := m.Run()
+	db.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// resetDB empties every table but the seeded admin account, and the QR
+// directory, so each test starts from a fresh install.
+func resetDB(t *testing.T) {
+	t.Helper()
+	for _, table := range []string{"faculty", "dtr", "breaks", "holidays", "locked_periods", "audit_log", "sessions"} {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("DELETE FROM admins WHERE username<>?", adminUser); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(qrDir, "*"))
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
+
+// setVar sets a config variable for the duration of the test.
+func setVar[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// addFaculty inserts an active faculty working Monday to Friday and returns
+// their id and token.
+func addFaculty(t *testing.T, name, role string, rate float64) (int, string) {
+	t.Helper()
+	token := randToken()
+	res, err := db.Exec("INSERT INTO faculty (name, role, rate_per_hour, token, work_days) VALUES (?,?,?,?,?)",
+		name, role, rate, token, defaultWorkDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id), token
+}
+
+// at parses "2006-01-02 15:04" in appLoc.
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04", s, appLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// addSession records a session from in to out ("" leaves it open) and
+// returns its id.
+func addSession(t *testing.T, fid int, in, out string) int {
+	t.Helper()
+	var outTime any
+	if out != "" {
+		outTime = at(t, out)
+	}
+	res, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, at(t, in), outTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// addOutOnly records a clock-out with no clock-in, as a missed morning scan
+// leaves, and returns its id.
+func addOutOnly(t *testing.T, fid int, out string) int {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO dtr (faculty_id, out_time) VALUES (?,?)", fid, at(t, out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// signIn adds a signed-in session cookie for an account of the given role.
+func signIn(t *testing.T, r *http.Request, role string) *http.Request {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	session, _ := store.Get(r, "session")
+	session.Values["authenticated"] = true
+	session.Values["username"] = adminUser
+	session.Values["role"] = role
+	session.Values["expires"] = appNow().Add(time.Hour).Unix()
+	if err := session.Save(r, rec); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+// count runs a COUNT(*) query.
+func count(t *testing.T, q string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(q, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// uploadRequest builds a multipart POST carrying content as the named file
+// field, plus any extra form values.
+func uploadRequest(t *testing.T, path, field, content string, extra url.Values) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, vs := range extra {
+		for _, v := range vs {
+			mw.WriteField(k, v)
+		}
+	}
+	fw, err := mw.CreateFormFile(field, "upload.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestQRFileConditionalGet(t *testing.T) {
+	resetDB(t)
+	if err := os.WriteFile(filepath.Join(qrDir, "abcd1234.png"), []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	first := httptest.NewRecorder()
+	handleQRFile(first, httptest.NewRequest("GET", "/qrs/abcd1234.png", nil))
+	etag := first.Header().Get("ETag")
+	if first.Code != 200 || etag == "" || first.Header().Get("Cache-Control") == "" {
+		t.Fatalf("first request: status %d, ETag %q, Cache-Control %q", first.Code, etag, first.Header().Get("Cache-Control"))
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"matching etag", etag, http.StatusNotModified},
+		{"stale etag", `"0-0"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/qrs/abcd1234.png", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			rec := httptest.NewRecorder()
+			handleQRFile(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestFacultyAddLeavesNoOrphanQR(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       string
+		failInsert bool
+		want       int
+		wantQR     bool
+	}{
+		{"added", "120", false, http.StatusFound, true},
+		{"invalid rate", "abc", false, http.StatusBadRequest, false},
+		{"insert fails", "120", true, http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			if tt.failInsert {
+				if _, err := db.Exec("CREATE TRIGGER fail_insert BEFORE INSERT ON faculty BEGIN SELECT RAISE(ABORT, 'disk full'); END"); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { db.Exec("DROP TRIGGER fail_insert") })
+			}
+			form := url.Values{"name": {"Ana Cruz"}, "role": {"Teacher"}, "rate": {tt.rate}, "token": {"anacruz1"}}
+			req := httptest.NewRequest("POST", "/faculty/add", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleFacultyAdd(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.rate == "abc" && !strings.Contains(rec.Body.String(), "Invalid rate") {
+				t.Errorf("rate error not shown: %s", rec.Body)
+			}
+			_, err := os.Stat(filepath.Join(qrDir, "anacruz1.png"))
+			if hasQR := err == nil; hasQR != tt.wantQR {
+				t.Errorf("QR file exists = %v, want %v", hasQR, tt.wantQR)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM faculty"); (n == 1) != tt.wantQR {
+				t.Errorf("%d faculty rows after the request", n)
+			}
+		})
+	}
+}
+
+func TestScanAllowlist(t *testing.T) {
+	resetDB(t)
+	_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	nets, err := parseCIDRs("10.0.0.0/8, 192.168.1.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		allow  []*net.IPNet
+		remote string
+		want   int
+	}{
+		{"allowed", nets, "10.1.2.3:5000", http.StatusOK},
+		{"blocked", nets, "203.0.113.7:5000", http.StatusForbidden},
+		{"empty allowlist", nil, "203.0.113.7:5000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &scanAllowlist, tt.allow)
+			req := httptest.NewRequest("GET", "/scan/"+token, nil)
+			req.RemoteAddr = tt.remote
+			rec := httptest.NewRecorder()
+			handleScan(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "login.html"), []byte("custom login"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := tplFS.ReadFile("tmpl/day.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		dir  string
+		file string
+		want string
+	}{
+		{"override present", dir, "tmpl/login.html", "custom login"},
+		{"override missing", dir, "tmpl/day.html", string(embedded)},
+		{"no override dir", "", "tmpl/day.html", string(embedded)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &templateDir, tt.dir)
+			b, err := readTemplate(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %.40q, want %.40q", b, tt.want)
+			}
+		})
+	}
+}
+
+// carryCookies copies the cookies a response set onto the next request, the
+// way a browser following the redirect would.
+func carryCookies(rec *httptest.ResponseRecorder, next *http.Request) *http.Request {
+	for _, c := range rec.Result().Cookies() {
+		next.AddCookie(c)
+	}
+	return next
+}
+
+func TestFlashShownOnce(t *testing.T) {
+	resetDB(t)
+	rec := httptest.NewRecorder()
+	setFlash(rec, httptest.NewRequest("POST", "/faculty/delete", nil), "Faculty deleted.")
+
+	tests := []struct {
+		name  string
+		shown bool
+	}{
+		{"after redirect", true},
+		{"on reload", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := carryCookies(rec, httptest.NewRequest("GET", "/", nil))
+			rec = httptest.NewRecorder()
+			handleHome(rec, req)
+			if rec.Code != 200 {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			if shown := strings.Contains(rec.Body.String(), "Faculty deleted."); shown != tt.shown {
+				t.Errorf("flash shown = %v, want %v", shown, tt.shown)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		hours         float64
+		decimal, hhmm string
+	}{
+		{0, "0.00", "0:00"},
+		{7.75, "7.75", "7:45"},
+		{8, "8.00", "8:00"},
+		{0.5, "0.50", "0:30"},
+		{1.0 / 60, "0.02", "0:01"},
+		{9.99, "9.99", "9:59"},
+		{7.999, "8.00", "8:00"},
+		{-1.25, "-1.25", "-1:15"},
+		{25.5, "25.50", "25:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decimal, func(t *testing.T) {
+			setVar(t, &hoursFormat, "decimal")
+			if got := formatDuration(tt.hours); got != tt.decimal {
+				t.Errorf("decimal: got %q, want %q", got, tt.decimal)
+			}
+			setVar(t, &hoursFormat, "hhmm")
+			if got := formatDuration(tt.hours); got != tt.hhmm {
+				t.Errorf("hh:mm: got %q, want %q", got, tt.hhmm)
+			}
+		})
+	}
+}
+
+func TestFacultyDuplicate(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		rate float64
+	}{
+		{"teacher", "Teacher", 120},
+		{"staff", "Staff", 85.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			src, srcToken := addFaculty(t, "Ana Cruz", tt.role, tt.rate)
+			rec := httptest.NewRecorder()
+			handleFacultyDuplicate(rec, httptest.NewRequest("POST", "/faculty/duplicate?id="+strconv.Itoa(src), nil))
+			if rec.Code != http.StatusSeeOther {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var name, role, token string
+			var rate float64
+			if err := db.QueryRow("SELECT name, role, rate_per_hour, token FROM faculty WHERE id<>?", src).Scan(&name, &role, &rate, &token); err != nil {
+				t.Fatal(err)
+			}
+			if token == "" || token == srcToken {
+				t.Errorf("clone token %q is not new", token)
+			}
+			if role != tt.role || rate != tt.rate {
+				t.Errorf("clone is %s at %.2f, want %s at %.2f", role, rate, tt.role, tt.rate)
+			}
+			if name != "Copy of Ana Cruz" {
+				t.Errorf("clone name %q", name)
+			}
+			if _, err := os.Stat(filepath.Join(qrDir, token+".png")); err != nil {
+				t.Errorf("clone QR: %v", err)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handleFacultyDuplicate(rec, httptest.NewRequest("POST", "/faculty/duplicate?id=999999", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id: got %d, want 404", rec.Code)
+	}
+}
+
+func TestPayrollDateParams(t *testing.T) {
+	resetDB(t)
+	today := dayStart(appNow())
+	monthStart := today.AddDate(0, 0, 1-today.Day())
+	tests := []struct {
+		name      string
+		query     string
+		want      int
+		wantStart string
+		wantError string
+	}{
+		{"empty start", "?start=&end=" + today.Format("2006-01-02"), 200, monthStart.Format("2006-01-02"), ""},
+		{"no params", "", 200, monthStart.Format("2006-01-02"), ""},
+		{"malformed start", "?start=2026-13-45&end=2026-10-31", 400, "", "Invalid start date"},
+		{"malformed end", "?start=2026-10-01&end=yesterday", 400, "", "Invalid end date"},
+		{"end before start", "?start=2026-10-15&end=2026-10-01", 400, "", "is before start date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handlePayroll(rec, httptest.NewRequest("GET", "/payroll"+tt.query, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+			body := rec.Body.String()
+			if tt.wantStart != "" && !strings.Contains(body, `value="`+tt.wantStart+`"`) {
+				t.Errorf("form does not default start to %s", tt.wantStart)
+			}
+			if tt.wantError != "" && !strings.Contains(body, tt.wantError) {
+				t.Errorf("page does not show %q", tt.wantError)
+			}
+			if strings.Contains(body, "0001-01-01") {
+				t.Error("page shows the zero date")
+			}
+		})
+	}
+}
+
+func TestFacultySort(t *testing.T) {
+	resetDB(t)
+	ben, _ := addFaculty(t, "ben Reyes", "Teacher", 150)
+	ana, _ := addFaculty(t, "Ana Cruz", "staff", 90)
+	cora, _ := addFaculty(t, "Cora Lim", "Librarian", 120)
+	if _, err := db.Exec("UPDATE faculty SET active=0 WHERE id=?", ana); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sort, dir string
+		wantKey   string
+		want      []int
+	}{
+		{"name", "asc", "name", []int{ana, ben, cora}},
+		{"name", "desc", "name", []int{cora, ben, ana}},
+		{"role", "asc", "role", []int{cora, ana, ben}},
+		{"rate", "asc", "rate", []int{ana, cora, ben}},
+		{"rate", "desc", "rate", []int{ben, cora, ana}},
+		{"active", "asc", "active", []int{ana, ben, cora}},
+		{"", "", "id", []int{ben, ana, cora}},
+		{"rate; DROP TABLE faculty", "asc", "id", []int{ben, ana, cora}},
+		{"name", "sideways", "name", []int{ana, ben, cora}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort+" "+tt.dir, func(t *testing.T) {
+			orderBy, key, _ := facultyOrderBy(tt.sort, tt.dir)
+			if key != tt.wantKey {
+				t.Errorf("sort key %q, want %q", key, tt.wantKey)
+			}
+			rs, err := db.Query("SELECT id FROM faculty" + orderBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Close()
+			var got []int
+			for rs.Next() {
+				var id int
+				rs.Scan(&id)
+				got = append(got, id)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLitePragmas(t *testing.T) {
+	tests := []struct {
+		name string
+		ms   int
+	}{
+		{"default", 5000},
+		{"slow disk", 30000},
+		{"no wait", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := sql.Open("sqlite", sqliteDSN(filepath.Join(t.TempDir(), "dtr.db"), tt.ms))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			var timeout int
+			var mode string
+			if err := conn.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+				t.Fatal(err)
+			}
+			if timeout != tt.ms || mode != "wal" {
+				t.Errorf("busy_timeout %d, journal_mode %q, want %d and wal", timeout, mode, tt.ms)
+			}
+		})
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) { panic("nil map in payroll") })
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	srv := httptest.NewServer(recoverPanics(mux))
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/panic", http.StatusInternalServerError},
+		{"/ok", http.StatusOK},
+		{"/panic", http.StatusInternalServerError},
+		{"/ok", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatalf("%s: server stopped answering: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+		if strings.Contains(string(body), "nil map") {
+			t.Errorf("%s: panic value leaked to the client", tt.path)
+		}
+	}
+}
+
+func TestUploadTooLarge(t *testing.T) {
+	setVar(t, &maxUploadBytes, 1<<10)
+	big := strings.Repeat("2026-12-25,regular,2\n", 100)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		content string
+		want    int
+	}{
+		{"faculty import", handleFacultyImport, big, http.StatusRequestEntityTooLarge},
+		{"holiday import", handleHolidaysImport, big, http.StatusRequestEntityTooLarge},
+		{"within the limit", handleHolidaysImport, "2026-12-25,regular,2\n", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			rec := httptest.NewRecorder()
+			tt.handler(rec, uploadRequest(t, "/import", "file", tt.content, nil))
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestFacultyAddCustomToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"custom token", "CARD-0042", http.StatusFound},
+		{"duplicate token", "taken01", http.StatusConflict},
+		{"invalid token", "../etc", http.StatusBadRequest},
+		{"too short", "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			if _, err := db.Exec("INSERT INTO faculty (name, role, rate_per_hour, token, work_days) VALUES ('Ben Reyes', 'Teacher', 100, 'taken01', ?)", defaultWorkDays); err != nil {
+				t.Fatal(err)
+			}
+			form := url.Values{"name": {"Ana Cruz"}, "role": {"Teacher"}, "rate": {"120"}, "token": {tt.token}}
+			req := httptest.NewRequest("POST", "/faculty/add", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleFacultyAdd(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			added := tt.want == http.StatusFound
+			if n := count(t, "SELECT COUNT(*) FROM faculty WHERE name='Ana Cruz' AND token=?", tt.token); (n == 1) != added {
+				t.Errorf("faculty with token %q: %d rows", tt.token, n)
+			}
+			if _, err := os.Stat(filepath.Join(qrDir, tt.token+".png")); (err == nil) != added {
+				t.Errorf("QR file for %q exists = %v", tt.token, err == nil)
+			}
+		})
+	}
+}
+
+func TestBrandingInPages(t *testing.T) {
+	resetDB(t)
+	setVar(t, &appTitle, "Bantay Oras")
+	setVar(t, &schoolName, "San Lorenzo Academy")
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		path       string
+		withSchool bool
+	}{
+		{"login", handleLoginPage, "/login", true},
+		{"home", handleHome, "/", true},
+		{"payroll", handlePayroll, "/payroll", false},
+		{"day report", handleDayReport, "/report/day?date=2026-10-14", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest("GET", tt.path, nil))
+			body := rec.Body.String()
+			if rec.Code != 200 || !strings.Contains(body, "Bantay Oras") {
+				t.Errorf("status %d; configured title missing", rec.Code)
+			}
+			if tt.withSchool && !strings.Contains(body, "San Lorenzo Academy") {
+				t.Error("configured school name missing")
+			}
+		})
+	}
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logf(r, "handler line")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/scan/abc", 200},
+		{"/missing", 404},
+	}
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			logged.Reset()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			id := rec.Header().Get("X-Request-ID")
+			if id == "" || seen[id] {
+				t.Fatalf("X-Request-ID %q is missing or reused", id)
+			}
+			seen[id] = true
+			out := logged.String()
+			for _, want := range []string{"[" + id + "] handler line", fmt.Sprintf("[%s] GET %s %d", id, tt.path, tt.status)} {
+				if !strings.Contains(out, want) {
+					t.Errorf("log lacks %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestFacultyNotes(t *testing.T) {
+	resetDB(t)
+	post := func(h http.HandlerFunc, path string, form url.Values) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code
+	}
+	if code := post(handleFacultyAdd, "/faculty/add", url.Values{"name": {"Ana Cruz"}, "role": {"Teacher"}, "rate": {"100"}, "notes": {"  on leave until May  "}}); code != http.StatusFound {
+		t.Fatalf("add: got %d", code)
+	}
+	var id int
+	if err := db.QueryRow("SELECT id FROM faculty WHERE name='Ana Cruz'").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		notes string // "" skips the edit and checks the notes from add
+		code  int
+		want  string
+	}{
+		{"saved on add", "", 0, "on leave until May"},
+		{"edited", "back from leave", http.StatusSeeOther, "back from leave"},
+		{"too long is refused", strings.Repeat("x", maxNotesLen+1), http.StatusBadRequest, "back from leave"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.notes != "" {
+				if code := post(handleFacultyNotes, "/faculty/notes", url.Values{"id": {strconv.Itoa(id)}, "notes": {tt.notes}}); code != tt.code {
+					t.Fatalf("edit: got %d, want %d", code, tt.code)
+				}
+			}
+			var notes string
+			if err := db.QueryRow("SELECT notes FROM faculty WHERE id=?", id).Scan(&notes); err != nil {
+				t.Fatal(err)
+			}
+			if notes != tt.want {
+				t.Errorf("stored notes %q, want %q", notes, tt.want)
+			}
+			for _, page := range []struct {
+				handler http.HandlerFunc
+				path    string
+			}{{handleHome, "/"}, {handleDayReport, "/report/day?date=2026-10-14"}} {
+				rec := httptest.NewRecorder()
+				page.handler(rec, httptest.NewRequest("GET", page.path, nil))
+				if !strings.Contains(rec.Body.String(), tt.want) {
+					t.Errorf("%s does not show the notes", page.path)
+				}
+			}
+		})
+	}
+
+	if code := post(handleFacultyNotes, "/faculty/notes", url.Values{"id": {"999999"}, "notes": {"x"}}); code != http.StatusNotFound {
+		t.Errorf("unknown faculty: got %d, want 404", code)
+	}
+}
+
+func TestLoginPageRenders(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		form    url.Values
+		code    int
+		showErr bool
+	}{
+		{"get", "GET", nil, http.StatusOK, false},
+		{"bad credentials", "POST", url.Values{"username": {"nobody"}, "password": {"wrong"}}, http.StatusOK, true},
+		{"other method", "PUT", nil, http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/login", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleLoginPage(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if got := strings.Contains(rec.Body.String(), "Invalid username or password"); got != tt.showErr {
+				t.Errorf("error shown = %v, want %v", got, tt.showErr)
+			}
+			if tt.code == http.StatusOK && !strings.Contains(rec.Body.String(), "</html>") {
+				t.Error("page was cut short")
+			}
+		})
+	}
+}
+
+func TestLimitPDF(t *testing.T) {
+	tests := []struct {
+		name     string
+		slots    int
+		requests int
+		wait     time.Duration
+		served   int
+	}{
+		{"queued within the wait", 2, 6, 5 * time.Second, 6},
+		{"turned away after the wait", 1, 3, 20 * time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &pdfSlots, make(chan struct{}, tt.slots))
+			setVar(t, &maxPDFRenders, tt.slots)
+			setVar(t, &pdfQueueWait, tt.wait)
+
+			var mu sync.Mutex
+			running, peak := 0, 0
+			release := make(chan struct{})
+			h := limitPDF(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+				<-release
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+
+			codes := make(chan int, tt.requests)
+			for i := 0; i < tt.requests; i++ {
+				go func() {
+					rec := httptest.NewRecorder()
+					h(rec, httptest.NewRequest("GET", "/timesheet.pdf", nil))
+					codes <- rec.Code
+				}()
+			}
+			// let the renders start, and any over the wait give up, before releasing them
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+			served := 0
+			for i := 0; i < tt.requests; i++ {
+				switch code := <-codes; code {
+				case http.StatusOK:
+					served++
+				case http.StatusServiceUnavailable:
+				default:
+					t.Errorf("unexpected status %d", code)
+				}
+			}
+			if served != tt.served {
+				t.Errorf("%d requests served, want %d", served, tt.served)
+			}
+			if peak > tt.slots {
+				t.Errorf("%d renders ran at once, limit %d", peak, tt.slots)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	latest := len(migrations)
+	tests := []struct {
+		name    string
+		build   string
+		drop    bool // forget the latest migration, as if it were still pending
+		version string
+		schema  int
+	}{
+		{"release build", "v1.4.0", false, "v1.4.0", latest},
+		{"pending migration", "v1.4.0", true, "v1.4.0", latest - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &buildVersion, tt.build)
+			if tt.drop {
+				var appliedAt time.Time
+				if err := db.QueryRow("SELECT applied_at FROM schema_migrations WHERE version=?", latest).Scan(&appliedAt); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := db.Exec("DELETE FROM schema_migrations WHERE version=?", latest); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() {
+					db.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", latest, appliedAt)
+				})
+			}
+			rec := httptest.NewRecorder()
+			handleVersion(rec, httptest.NewRequest("GET", "/admin/version", nil))
+			var got struct {
+				Version         string `json:"version"`
+				SchemaVersion   int    `json:"schema_version"`
+				LatestMigration int    `json:"latest_migration"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("status %d: %v", rec.Code, err)
+			}
+			if got.Version != tt.version || got.SchemaVersion != tt.schema || got.LatestMigration != latest {
+				t.Errorf("got %+v, want version %s, schema %d of %d", got, tt.version, tt.schema, latest)
+			}
+		})
+	}
+}
+
+func TestFacultyBulkRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		code    int
+		changed int
+		want    []float64 // rates of teacher A, teacher B, salaried teacher, staff
+	}{
+		{"absolute rate", url.Values{"role": {"teacher"}, "rate": {"175"}}, http.StatusOK, 2, []float64{175, 175, 0, 100}},
+		{"percentage bump", url.Values{"role": {" Teacher "}, "percent": {"10"}}, http.StatusOK, 2, []float64{110, 165, 0, 100}},
+		{"percentage cut", url.Values{"role": {"Staff"}, "percent": {"-5"}}, http.StatusOK, 1, []float64{100, 150, 0, 95}},
+		{"unchanged rate", url.Values{"role": {"Staff"}, "rate": {"100"}}, http.StatusOK, 0, []float64{100, 150, 0, 100}},
+		{"both given", url.Values{"role": {"Teacher"}, "rate": {"1"}, "percent": {"1"}}, http.StatusBadRequest, 0, []float64{100, 150, 0, 100}},
+		{"missing role", url.Values{"rate": {"1"}}, http.StatusBadRequest, 0, []float64{100, 150, 0, 100}},
+		{"negative rate", url.Values{"role": {"Teacher"}, "rate": {"-1"}}, http.StatusBadRequest, 0, []float64{100, 150, 0, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			a, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			b, _ := addFaculty(t, "Ben Reyes", "TEACHER", 150)
+			salaried, _ := addFaculty(t, "Cora Lim", "Teacher", 0)
+			staff, _ := addFaculty(t, "Dan Sy", "Staff", 100)
+			if _, err := db.Exec("UPDATE faculty SET employment_type='salaried', monthly_salary=30000 WHERE id=?", salaried); err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("POST", "/faculty/bulk-rate", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleFacultyBulkRate(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.code == http.StatusOK {
+				var got struct{ Changed int }
+				if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Changed != tt.changed {
+					t.Errorf("changed %d, want %d", got.Changed, tt.changed)
+				}
+			}
+			for i, id := range []int{a, b, salaried, staff} {
+				var rate float64
+				if err := db.QueryRow("SELECT rate_per_hour FROM faculty WHERE id=?", id).Scan(&rate); err != nil {
+					t.Fatal(err)
+				}
+				if rate != tt.want[i] {
+					t.Errorf("faculty %d rate %.2f, want %.2f", i, rate, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFacultyAddRoleDefaultRate(t *testing.T) {
+	setVar(t, &roleDefaultRates, map[string]float64{"teacher": 120, "staff": 90})
+	tests := []struct {
+		name string
+		role string
+		rate string
+		code int
+		want float64
+	}{
+		{"blank rate uses the role default", "Teacher", "", http.StatusFound, 120},
+		{"role matched case-insensitively", " STAFF ", "", http.StatusFound, 90},
+		{"explicit rate wins", "Teacher", "150", http.StatusFound, 150},
+		{"unknown role needs a rate", "Librarian", "", http.StatusBadRequest, 0},
+		{"unknown role with a rate", "Librarian", "80", http.StatusFound, 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			form := url.Values{"name": {"Ana Cruz"}, "role": {tt.role}, "rate": {tt.rate}}
+			req := httptest.NewRequest("POST", "/faculty/add", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleFacultyAdd(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.code != http.StatusFound {
+				if n := count(t, "SELECT COUNT(*) FROM faculty"); n != 0 {
+					t.Error("faculty added without a rate")
+				}
+				return
+			}
+			var rate float64
+			if err := db.QueryRow("SELECT rate_per_hour FROM faculty WHERE name='Ana Cruz'").Scan(&rate); err != nil {
+				t.Fatal(err)
+			}
+			if rate != tt.want {
+				t.Errorf("rate %.2f, want %.2f", rate, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		role    string
+		want    string // stored role, "" when refused
+	}{
+		{"no allowlist takes any role", nil, "Librarian", "Librarian"},
+		{"allowed role", []string{"Faculty", "Staff"}, "Staff", "Staff"},
+		{"allowed role takes the listed spelling", []string{"Faculty", "Staff"}, " staff ", "Staff"},
+		{"unknown role refused", []string{"Faculty", "Staff"}, "Librarian", ""},
+		{"blank role refused", []string{"Faculty", "Staff"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &allowedRoles, tt.allowed)
+			form := url.Values{"name": {"Ana Cruz"}, "role": {tt.role}, "rate": {"100"}}
+			req := httptest.NewRequest("POST", "/faculty/add", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleFacultyAdd(rec, req)
+
+			f, err := parseFacultyRecord(importRecord{Line: 2, Fields: []string{"Ben Reyes", tt.role, "100"}}, map[string]bool{})
+			if tt.want == "" {
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("add: got %d, want %d", rec.Code, http.StatusBadRequest)
+				}
+				if n := count(t, "SELECT COUNT(*) FROM faculty"); n != 0 {
+					t.Error("faculty added with a role not on the allowlist")
+				}
+				if err == nil {
+					t.Error("import accepted a role not on the allowlist")
+				}
+				return
+			}
+			if rec.Code != http.StatusFound {
+				t.Fatalf("add: got %d: %s", rec.Code, rec.Body)
+			}
+			var role string
+			if err := db.QueryRow("SELECT role FROM faculty WHERE name='Ana Cruz'").Scan(&role); err != nil {
+				t.Fatal(err)
+			}
+			if role != tt.want {
+				t.Errorf("add stored role %q, want %q", role, tt.want)
+			}
+			if err != nil || f.role != tt.want {
+				t.Errorf("import: role %q, err %v; want %q", f.role, err, tt.want)
+			}
+		})
+	}
+}
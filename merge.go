@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ---------- SESSION MERGING ----------
+
+// mergeGroups groups each faculty's sessions so that a session clocked in
+// less than gap after the previous one clocked out joins its group, as a
+// scanner misfire leaves them. sessions must be ordered by clock-in. Sessions
+// clocked out before they were clocked in are never merged; an open session
+// can end a group. Groups are in order of their first clock-in.
+func mergeGroups(sessions []session, gap time.Duration) [][]session {
+	var groups [][]session
+	last := map[int]int{} // faculty → index of their latest group
+	for _, s := range sessions {
+		if i, ok := last[s.FacultyID]; ok {
+			prev := groups[i][len(groups[i])-1]
+			bad := s.Out.Valid && s.Out.Time.Before(s.In)
+			if prev.Out.Valid && !prev.Out.Time.Before(prev.In) && !bad {
+				if d := s.In.Sub(prev.Out.Time); d >= 0 && d < gap {
+					groups[i] = append(groups[i], s)
+					continue
+				}
+			}
+		}
+		last[s.FacultyID] = len(groups)
+		groups = append(groups, []session{s})
+	}
+	return groups
+}
+
+// mergeSessions joins the sessions of each merge group into one running from
+// the first clock-in to the last clock-out. The stored rows are untouched.
+func mergeSessions(sessions []session, gap time.Duration) []session {
+	groups := mergeGroups(sessions, gap)
+	out := make([]session, 0, len(groups))
+	for _, g := range groups {
+		m := g[0]
+		for _, s := range g[1:] {
+			m.Out = s.Out
+			m.Breaks = append(m.Breaks, s.Breaks...)
+			m.OvertimeApproved = m.OvertimeApproved || s.OvertimeApproved
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// handleMergeSessions permanently merges back-to-back sessions less than
+// mergeGap apart into their first row, moving breaks along and deleting the
+// rest. Sessions in locked or paid periods are left alone. Without
+// confirm=yes it only reports how many sessions would be merged.
+func handleMergeSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	sessions, err := querySessions(time.Time{}, appNow().AddDate(0, 0, 1), 0)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var merges [][]session
+	for _, g := range mergeGroups(sessions, mergeGap) {
+		if len(g) < 2 {
+			continue
+		}
+		locked := false
+		for _, s := range g {
+			if err := checkUnlocked(sql.NullTime{Time: s.In, Valid: true}, s.Out); err != nil {
+				locked = true
+				break
+			}
+		}
+		if !locked {
+			merges = append(merges, g)
+		}
+	}
+	removed := 0
+	for _, g := range merges {
+		removed += len(g) - 1
+	}
+
+	if r.FormValue("confirm") != "yes" {
+		http.Error(w, fmt.Sprintf("This would merge %d sessions less than %s apart into %d; resend with confirm=yes to proceed.",
+			removed+len(merges), mergeGap, len(merges)), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer tx.Rollback()
+	for _, g := range merges {
+		first, end := g[0], g[len(g)-1]
+		approved := false
+		for _, s := range g {
+			approved = approved || s.OvertimeApproved
+		}
+		if _, err := tx.Exec("UPDATE dtr SET out_time=?, overtime_approved=? WHERE id=?", end.Out, approved, first.ID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		for _, s := range g[1:] {
+			if _, err := tx.Exec("UPDATE breaks SET dtr_id=? WHERE dtr_id=?", first.ID, s.ID); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			if _, err := tx.Exec("DELETE FROM dtr WHERE id=?", s.ID); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "dtr.merge", fmt.Sprintf("gap %s", mergeGap), fmt.Sprintf("merged %d sessions into %d", removed+len(merges), len(merges)))
+	setFlash(w, r, fmt.Sprintf("Merged %d back-to-back sessions into %d.", removed+len(merges), len(merges)))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeSessions(t *testing.T) {
+	base := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	sess := func(fid int, in, out time.Duration) session {
+		return session{FacultyID: fid, In: base.Add(in), Out: sql.NullTime{Time: base.Add(out), Valid: true}}
+	}
+	open := func(fid int, in time.Duration) session {
+		return session{FacultyID: fid, In: base.Add(in)}
+	}
+	tests := []struct {
+		name     string
+		sessions []session
+		want     int
+	}{
+		{"10 seconds apart", []session{sess(1, 0, 4*time.Hour), sess(1, 4*time.Hour+10*time.Second, 8*time.Hour)}, 1},
+		{"a real break", []session{sess(1, 0, 4*time.Hour), sess(1, 5*time.Hour, 8*time.Hour)}, 2},
+		{"exactly the gap", []session{sess(1, 0, 4*time.Hour), sess(1, 4*time.Hour+30*time.Second, 8*time.Hour)}, 2},
+		{"different faculty", []session{sess(1, 0, 4*time.Hour), sess(2, 4*time.Hour+10*time.Second, 8*time.Hour)}, 2},
+		{"open session ends the group", []session{sess(1, 0, 4*time.Hour), open(1, 4*time.Hour+5*time.Second)}, 1},
+		{"out before in is never merged", []session{sess(1, 0, 4*time.Hour), sess(1, 4*time.Hour+5*time.Second, 3*time.Hour)}, 2},
+		{"three misfires", []session{sess(1, 0, time.Hour), sess(1, time.Hour+2*time.Second, 2*time.Hour), sess(1, 2*time.Hour+3*time.Second, 3*time.Hour)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeSessions(tt.sessions, 30*time.Second)
+			if len(got) != tt.want {
+				t.Fatalf("got %d sessions, want %d", len(got), tt.want)
+			}
+			last := tt.sessions[len(tt.sessions)-1]
+			if tt.want == 1 && (!got[0].In.Equal(tt.sessions[0].In) || got[0].Out != last.Out) {
+				t.Errorf("merged session runs %v to %v, want first in to last out", got[0].In, got[0].Out)
+			}
+		})
+	}
+}
+
+func TestMergeSessionsCleanup(t *testing.T) {
+	resetDB(t)
+	setVar(t, &mergeGap, 30*time.Second)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	in := at(t, "2026-10-14 08:00")
+	var ids []int64
+	for _, s := range [][2]time.Time{
+		{in, in.Add(4 * time.Hour)},
+		{in.Add(4*time.Hour + 10*time.Second), in.Add(8 * time.Hour)},
+	} {
+		res, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, s[0], s[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		ids = append(ids, id)
+	}
+	if _, err := db.Exec("INSERT INTO breaks (dtr_id, start_time, end_time) VALUES (?,?,?)", ids[1], in.Add(5*time.Hour), in.Add(5*time.Hour+30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		confirm string
+		code    int
+		rows    int
+	}{
+		{"preview keeps the rows", "", http.StatusBadRequest, 2},
+		{"confirmed merge", "yes", http.StatusSeeOther, 1},
+		{"nothing left to merge", "yes", http.StatusSeeOther, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/merge-sessions", strings.NewReader(url.Values{"confirm": {tt.confirm}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handleMergeSessions(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM dtr"); n != tt.rows {
+				t.Errorf("%d rows, want %d", n, tt.rows)
+			}
+		})
+	}
+	var out time.Time
+	if err := db.QueryRow("SELECT out_time FROM dtr WHERE id=?", ids[0]).Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(in.Add(8 * time.Hour)) {
+		t.Errorf("merged row ends %v, want the second session's clock-out", out)
+	}
+	if n := count(t, "SELECT COUNT(*) FROM breaks WHERE dtr_id=?", ids[0]); n != 1 {
+		t.Error("the second session's break was not moved to the merged row")
+	}
+}
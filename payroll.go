@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ---------- PAYROLL ----------
+
+// payrollRow is one faculty's totals for a payroll period.
+type payrollRow struct {
+	FacultyID         int
+	Name              string
+	Role              string
+	EmploymentType    string
+	RatePerHour       float64 // per hour, or per day when RateUnit is "day"
+	RateUnit          string
+	MonthlySalary     float64
+	TotalHours        float64
+	OvertimeHours     float64
+	UnpaidOvertime    float64 // overtime hours on days with no approved session
+	OvertimeThreshold float64
+	HolidayHours      float64
+	DaysWorked        int     // days reaching dayRateMinHours, counted for all faculty
+	PaidHours         float64 // TotalHours plus holiday premium; what hourly pay is on
+	PaidDays          float64 // DaysWorked, holidays weighted by multiplier; what day pay is on
+	NegativeSessions  int     // sessions with out before in, excluded from pay
+	Flagged           bool    // NegativeSessions exceeds negativeSessionLimit
+	Pay               float64
+}
+
+// employmentTypes are the accepted faculty pay arrangements.
+var employmentTypes = map[string]bool{
+	"hourly":   true,
+	"salaried": true,
+}
+
+// rateUnits are what a faculty's rate is paid per.
+var rateUnits = map[string]bool{
+	"hour": true,
+	"day":  true,
+}
+
+// periodSalary prorates a monthly salary over the days in [start, end): each
+// day earns 1/n of its month's salary, where n is that month's length, so a
+// whole calendar month pays exactly the salary and two half-month periods
+// add up to it.
+func periodSalary(monthly float64, start, end time.Time) float64 {
+	var total float64
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+		total += monthly / float64(daysInMonth)
+	}
+	return roundMoney(total)
+}
+
+// overtimeThreshold returns the daily hours after which a role earns overtime.
+func overtimeThreshold(role string) float64 {
+	if h, ok := overtimeRoleHours[normalizeRole(role)]; ok {
+		return h
+	}
+	return overtimeDailyHours
+}
+
+// computePayroll totals every faculty's hours and pay for sessions clocked in
+// within [start, end), ordered by faculty id. Callers pass the day after the
+// last payroll day as end.
+func computePayroll(start, end time.Time) ([]payrollRow, float64, error) {
+	sessions, err := querySessions(start, end, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	byFaculty := map[int][]session{}
+	for _, s := range sessions {
+		byFaculty[s.FacultyID] = append(byFaculty[s.FacultyID], s)
+	}
+
+	// a session clocked in on the last day can run past midnight into a holiday
+	holidays, err := loadHolidays(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rs, err := db.Query("SELECT id, name, role, rate_per_hour, rate_unit, employment_type, monthly_salary, shift_end FROM faculty")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rs.Close()
+
+	var rows []payrollRow
+	var grandCents int64 // summed in centavos so the total never drifts from the rows
+	for rs.Next() {
+		var row payrollRow
+		var shiftEnd string
+		if err := rs.Scan(&row.FacultyID, &row.Name, &row.Role, &row.RatePerHour, &row.RateUnit, &row.EmploymentType, &row.MonthlySalary, &shiftEnd); err != nil {
+			return nil, 0, err
+		}
+		facSessions := byFaculty[row.FacultyID]
+		if mergeAtPayroll {
+			facSessions = mergeSessions(facSessions, mergeGap)
+		}
+		if capToShiftEnd && shiftEnd != "" {
+			facSessions = capSessions(facSessions, shiftEnd)
+		}
+		row.OvertimeThreshold = overtimeThreshold(row.Role)
+
+		// everything is summed in whole minutes and converted to hours once, so
+		// many short sessions can't accumulate floating-point drift
+		thresholdMinutes := int(math.Round(row.OvertimeThreshold * 60))
+		var minutes, overtime, unpaid, holidayMinutes int
+		var premium float64 // extra paid minutes from holiday multipliers
+		dayMinMinutes := int(math.Round(dayRateMinHours * 60))
+		// holiday minutes and premium are gathered by clock-in day, the day
+		// summarizeDays files the session under, so each can be capped at
+		// what that day is paid
+		holidayDays := map[string]holidayWork{}
+		for _, s := range facSessions {
+			key := s.In.In(appLoc).Format("2006-01-02")
+			m, p := holidaySplit(s, holidays)
+			hw := holidayDays[key]
+			hw.Minutes += m
+			hw.Premium += p
+			holidayDays[key] = hw
+		}
+		for key, d := range summarizeDays(facSessions) {
+			minutes += d.Minutes
+			row.NegativeSessions += d.Negative
+			if d.Minutes > 0 && d.Minutes >= dayMinMinutes {
+				row.DaysWorked++
+				if h, ok := holidays[key]; ok {
+					row.PaidDays += h.Multiplier
+				} else {
+					row.PaidDays++
+				}
+			}
+			dayPaid := d.Minutes
+			if d.Minutes > thresholdMinutes {
+				overtime += d.Minutes - thresholdMinutes
+				// overtime is reported either way but only paid once approved
+				if !d.OvertimeApproved {
+					unpaid += d.Minutes - thresholdMinutes
+					dayPaid = thresholdMinutes
+				}
+			}
+			hw := holidayDays[key]
+			holidayMinutes += hw.Minutes
+			premium += hw.premiumUpTo(dayPaid)
+		}
+		paidMinutes := roundTotal(minutes-unpaid) + roundMinutes(int(math.Round(premium)))
+		if minutes-unpaid < roundMinTotal {
+			paidMinutes = 0 // holiday premium on an exempt total isn't paid either
+		}
+		row.Flagged = row.NegativeSessions > negativeSessionLimit
+		row.TotalHours = minutesToHours(roundTotal(minutes))
+		row.OvertimeHours = minutesToHours(roundMinutes(overtime))
+		row.UnpaidOvertime = minutesToHours(roundMinutes(unpaid))
+		row.HolidayHours = minutesToHours(roundMinutes(holidayMinutes))
+		row.PaidHours = minutesToHours(paidMinutes)
+		if row.EmploymentType == "salaried" {
+			// attendance is still reported, but pay doesn't depend on it
+			row.Pay = periodSalary(row.MonthlySalary, start, end)
+		} else {
+			row.Pay = row.payAt(row.RatePerHour)
+		}
+		grandCents += toCents(row.Pay)
+		rows = append(rows, row)
+	}
+	if err := rs.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].FacultyID < rows[j].FacultyID })
+	return rows, float64(grandCents) / 100, nil
+}
+
+// payAt is what the row's attendance earns at rate, per hour or per day as
+// its RateUnit says.
+func (row payrollRow) payAt(rate float64) float64 {
+	if row.RateUnit == "day" {
+		return roundMoney(row.PaidDays * rate)
+	}
+	return roundMoney(row.PaidHours * rate)
+}
+
+// presentPay rounds each row's pay for display per moneyRound and returns
+// the grand total of the displayed amounts. computePayroll stays centavo-precise;
+// this is applied only by the views and exports.
+func presentPay(rows []payrollRow) float64 {
+	var grandCents int64
+	for i := range rows {
+		rows[i].Pay = displayMoney(rows[i].Pay)
+		grandCents += toCents(rows[i].Pay)
+	}
+	return float64(grandCents) / 100
+}
+
+// displayMoney rounds an amount the way pay is shown, per moneyRound.
+func displayMoney(v float64) float64 {
+	if moneyRound == "peso" {
+		return math.Round(v)
+	}
+	return roundMoney(v)
+}
+
+// formatMoney renders an amount for the pages, e.g. "₱1234.50"; every view
+// showing pay goes through it so they never disagree on rounding.
+func formatMoney(v float64) string {
+	return fmt.Sprintf("₱%.2f", displayMoney(v))
+}
+
+// roundMoney rounds an amount to the centavo.
+func roundMoney(v float64) float64 {
+	return float64(toCents(v)) / 100
+}
+
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// holidayWork is the holiday minutes worked on one day and the extra minutes
+// their multipliers earn.
+type holidayWork struct {
+	Minutes int
+	Premium float64
+}
+
+// premiumUpTo is the premium owed when only paid of the day's minutes are
+// paid: holiday minutes beyond that, such as unapproved overtime, earn no
+// premium either.
+func (hw holidayWork) premiumUpTo(paid int) float64 {
+	if hw.Minutes <= paid {
+		return hw.Premium
+	}
+	if paid <= 0 {
+		return 0
+	}
+	return hw.Premium * float64(paid) / float64(hw.Minutes)
+}
+
+// holidaySplit splits a session at each midnight and returns the whole
+// minutes, less breaks, that fall on holidays, plus the extra minutes their
+// multipliers earn. Only the holiday side of a session crossing midnight counts.
+func holidaySplit(s session, holidays map[string]holiday) (minutes int, premium float64) {
+	if !s.Out.Valid {
+		return 0, 0
+	}
+	for cur := s.In; cur.Before(s.Out.Time); {
+		segEnd := dayStart(cur).AddDate(0, 0, 1)
+		if s.Out.Time.Before(segEnd) {
+			segEnd = s.Out.Time
+		}
+		if h, ok := holidays[cur.In(appLoc).Format("2006-01-02")]; ok {
+			m := int(segEnd.Sub(cur)/time.Minute) - s.minutesOnBreak(cur, segEnd)
+			minutes += m
+			premium += float64(m) * (h.Multiplier - 1)
+		}
+		cur = segEnd
+	}
+	return minutes, premium
+}
+
+// handlePayrollValidate is a dry run of a pay period: it reports the anomaly
+// checks for the same start/end range as /payroll, without any pay figures,
+// so problems can be fixed before the period is finalized.
+func handlePayrollValidate(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parsePayrollRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	issues, err := findAnomalies(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if issues == nil {
+		issues = []anomaly{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Start  string    `json:"start"`
+		End    string    `json:"end"`
+		Pass   bool      `json:"pass"`
+		Issues []anomaly `json:"issues"`
+	}{start.Format("2006-01-02"), end.Format("2006-01-02"), len(issues) == 0, issues})
+}
+
+// handleRatePreview shows what one faculty would earn over a payroll range at
+// a hypothetical rate, in their rate unit, alongside their pay at the stored
+// rate. Nothing is saved. Salaried faculty are unaffected by the rate, so both
+// figures match.
+func handleRatePreview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Missing or invalid id")
+		return
+	}
+	rate, err := strconv.ParseFloat(r.FormValue("rate"), 64)
+	if err != nil || rate < 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid rate")
+		return
+	}
+	start, end, err := parsePayrollRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, _, err := computePayroll(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, row := range rows {
+		if row.FacultyID != id {
+			continue
+		}
+		preview := row.Pay
+		if row.EmploymentType != "salaried" {
+			preview = row.payAt(rate)
+		}
+		current, preview := displayMoney(row.Pay), displayMoney(preview)
+		writeJSON(w, http.StatusOK, struct {
+			FacultyID   int     `json:"faculty_id"`
+			Start       string  `json:"start"`
+			End         string  `json:"end"`
+			PaidHours   float64 `json:"paid_hours"`
+			CurrentRate float64 `json:"current_rate"`
+			CurrentPay  float64 `json:"current_pay"`
+			PreviewRate float64 `json:"preview_rate"`
+			PreviewPay  float64 `json:"preview_pay"`
+			Difference  float64 `json:"difference"`
+		}{id, start.Format("2006-01-02"), end.Format("2006-01-02"), row.PaidHours,
+			row.RatePerHour, current, rate, preview, roundMoney(preview - current)})
+		return
+	}
+	writeJSONError(w, http.StatusNotFound, "Faculty not found")
+}
+
+// handlePayrollDetailCSV lists every session behind a payroll range, one
+// per row, so the totals can be audited. Open sessions are included and
+// flagged, with a blank out time and zero counted hours, as are sessions
+// clocked out before they were clocked in.
+func handlePayrollDetailCSV(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parsePayrollRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sessions, err := querySessions(start, end.AddDate(0, 0, 1), 0)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	names := map[int]string{}
+	rs, err := db.Query("SELECT id, name FROM faculty")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for rs.Next() {
+		var id int
+		var name string
+		if err := rs.Scan(&id, &name); err != nil {
+			rs.Close()
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		names[id] = name
+	}
+	rs.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=payroll-detail-%s-to-%s.csv",
+		start.Format("2006-01-02"), end.Format("2006-01-02")))
+	if csvBOM {
+		w.Write([]byte("\uFEFF"))
+	}
+	csvw := csv.NewWriter(w)
+	csvw.Comma = csvDelimiter
+	defer csvw.Flush()
+
+	csvw.Write([]string{"SessionID", "FacultyID", "Name", "In", "Out", "Flag", "CountedHours", "OvertimeApproved"})
+	for _, s := range sessions {
+		out, flag, hours := "", "", 0.0
+		switch {
+		case !s.Out.Valid:
+			flag = "open"
+		case s.Out.Time.Before(s.In):
+			out, flag = s.Out.Time.In(appLoc).Format("2006-01-02 15:04:05"), "out before in"
+		default:
+			out = s.Out.Time.In(appLoc).Format("2006-01-02 15:04:05")
+			hours = sessionHours(s.In, s.Out.Time, s.minutesOnBreak(s.In, s.Out.Time))
+		}
+		csvw.Write([]string{
+			strconv.Itoa(s.ID), strconv.Itoa(s.FacultyID), names[s.FacultyID],
+			s.In.In(appLoc).Format("2006-01-02 15:04:05"), out, flag,
+			fmt.Sprintf("%.2f", hours),
+			strconv.FormatBool(s.OvertimeApproved),
+		})
+	}
+}
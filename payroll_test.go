@@ -0,0 +1,616 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// payrollByID runs computePayroll over [from, to) days and indexes the rows.
+func payrollByID(t *testing.T, from, to string) map[int]payrollRow {
+	t.Helper()
+	rows, _, err := computePayroll(at(t, from+" 00:00"), at(t, to+" 00:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[int]payrollRow{}
+	for _, r := range rows {
+		byID[r.FacultyID] = r
+	}
+	return byID
+}
+
+func TestOvertimeThresholdPerRole(t *testing.T) {
+	resetDB(t)
+	setVar(t, &overtimeDailyHours, 8.0)
+	setVar(t, &overtimeRoleHours, map[string]float64{"staff": 6})
+	teacher, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	staff, _ := addFaculty(t, "Ben Reyes", "Staff", 100)
+	guard, _ := addFaculty(t, "Cora Lim", "Guard", 100)
+	for _, fid := range []int{teacher, staff, guard} {
+		addSession(t, fid, "2026-10-14 07:00", "2026-10-14 16:00")
+	}
+	rows := payrollByID(t, "2026-10-14", "2026-10-15")
+
+	tests := []struct {
+		name      string
+		id        int
+		threshold float64
+		overtime  float64
+	}{
+		{"global default", teacher, 8, 1},
+		{"role override", staff, 6, 3},
+		{"unknown role", guard, 8, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rows[tt.id]
+			if r.OvertimeThreshold != tt.threshold || r.OvertimeHours != tt.overtime {
+				t.Errorf("threshold %.2f overtime %.2f, want %.2f and %.2f", r.OvertimeThreshold, r.OvertimeHours, tt.threshold, tt.overtime)
+			}
+		})
+	}
+}
+
+func TestGrandTotalNoDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		faculty int
+		rate    float64
+		want    float64
+	}{
+		{"three dimes", 3, 0.1, 0.3},
+		{"ten dimes", 10, 0.1, 1},
+		{"odd centavos", 7, 33.33, 233.31},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			for i := 0; i < tt.faculty; i++ {
+				fid, _ := addFaculty(t, "Ana Cruz", "Teacher", tt.rate)
+				addSession(t, fid, "2026-10-14 08:00", "2026-10-14 09:00")
+			}
+			rows, grand, err := computePayroll(at(t, "2026-10-14 00:00"), at(t, "2026-10-15 00:00"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			naive := 0.0
+			var cents int64
+			for _, r := range rows {
+				naive += r.Pay
+				cents += toCents(r.Pay)
+			}
+			if grand != tt.want || grand != float64(cents)/100 {
+				t.Errorf("grand total %v, want %v (row sum %d centavos, naive %v)", grand, tt.want, cents, naive)
+			}
+		})
+	}
+}
+
+func TestPayrollMinutesNoDrift(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions int
+		minutes  int
+		rate     float64
+		hours    float64
+		pay      float64
+	}{
+		{"7-minute sessions", 150, 7, 60, 17.5, 1050},
+		{"1-minute sessions", 300, 1, 120, 5, 600},
+		{"13-minute sessions", 90, 13, 45, 19.5, 877.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", tt.rate)
+			floatHours := 0.0
+			day := at(t, "2026-09-01 08:00")
+			for i := 0; i < tt.sessions; i++ {
+				// ten sessions a day, well under the overtime threshold
+				in := day.AddDate(0, 0, i/10).Add(time.Duration(i%10) * 30 * time.Minute)
+				out := in.Add(time.Duration(tt.minutes) * time.Minute)
+				if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, in, out); err != nil {
+					t.Fatal(err)
+				}
+				floatHours += out.Sub(in).Hours()
+			}
+			r := payrollByID(t, "2026-09-01", "2026-10-01")[fid]
+			if r.TotalHours != tt.hours || r.Pay != tt.pay {
+				t.Errorf("%.10f hours paid %.10f, want %.2f and %.2f (summing float hours gives %.10f)", r.TotalHours, r.Pay, tt.hours, tt.pay, floatHours)
+			}
+
+			rec := httptest.NewRecorder()
+			handlePayrollCSV(rec, httptest.NewRequest("GET", "/payroll.csv?start=2026-09-01&end=2026-09-30", nil))
+			records, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil || len(records) != 2 {
+				t.Fatalf("CSV: %v, %d records", err, len(records))
+			}
+			col := slices.Index(records[0], "TotalHours")
+			if got, want := records[1][col], fmt.Sprintf("%.2f", tt.hours); got != want {
+				t.Errorf("CSV TotalHours %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPayrollValidate(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addSession(t, fid, "2026-10-05 08:00", "2026-10-05 17:00")
+	addSession(t, fid, "2026-10-12 08:00", "")
+
+	tests := []struct {
+		name   string
+		query  string
+		pass   bool
+		issues []string
+	}{
+		{"clean range", "?start=2026-10-01&end=2026-10-07", true, nil},
+		{"open session", "?start=2026-10-08&end=2026-10-14", false, []string{"open session"}},
+		{"both", "?start=2026-10-01&end=2026-10-14", false, []string{"open session"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handlePayrollValidate(rec, httptest.NewRequest("GET", "/payroll/validate"+tt.query, nil))
+			var res struct {
+				Pass   bool      `json:"pass"`
+				Issues []anomaly `json:"issues"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("status %d: %v", rec.Code, err)
+			}
+			var kinds []string
+			for _, a := range res.Issues {
+				kinds = append(kinds, a.Kind)
+			}
+			if res.Pass != tt.pass || fmt.Sprint(kinds) != fmt.Sprint(tt.issues) {
+				t.Errorf("pass=%v issues %v, want pass=%v %v", res.Pass, kinds, tt.pass, tt.issues)
+			}
+			if strings.Contains(rec.Body.String(), `"pay`) {
+				t.Error("validation returned pay figures")
+			}
+		})
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	resetDB(t)
+	ana, _ := addFaculty(t, "Ana Cruz", "Teacher", 101.15)
+	ben, _ := addFaculty(t, "Ben Reyes", "Teacher", 99.99)
+	addSession(t, ana, "2026-10-14 08:00", "2026-10-14 15:30") // 7.5 h = 758.625
+	addSession(t, ben, "2026-10-14 08:00", "2026-10-14 08:30") // 0.5 h = 49.995
+
+	tests := []struct {
+		mode  string
+		pays  []string
+		total string
+	}{
+		{"centavo", []string{"758.63", "50.00"}, "808.63"},
+		{"peso", []string{"759.00", "50.00"}, "809.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			setVar(t, &moneyRound, tt.mode)
+			rows, _, err := computePayroll(at(t, "2026-10-14 00:00"), at(t, "2026-10-15 00:00"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			grand := presentPay(rows)
+			var pays []string
+			for _, r := range rows {
+				pays = append(pays, fmt.Sprintf("%.2f", r.Pay))
+			}
+			if fmt.Sprint(pays) != fmt.Sprint(tt.pays) || fmt.Sprintf("%.2f", grand) != tt.total {
+				t.Errorf("pays %v total %.2f, want %v and %s", pays, grand, tt.pays, tt.total)
+			}
+
+			rec := httptest.NewRecorder()
+			handlePayrollCSV(rec, httptest.NewRequest("GET", "/payroll.csv?start=2026-10-14&end=2026-10-14", nil))
+			records, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.pays {
+				row := records[i+1]
+				if got := row[len(row)-1]; got != want {
+					t.Errorf("CSV row %d pay %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHolidaySplit(t *testing.T) {
+	holidays := map[string]holiday{"2026-11-01": {Date: "2026-11-01", Type: "special", Multiplier: 1.3}}
+	tests := []struct {
+		name          string
+		in, out       string
+		brkIn, brkOut string // a break within the session, if set
+		minutes       int
+		premium       float64
+	}{
+		{"into a holiday", "2026-10-31 22:00", "2026-11-01 06:00", "", "", 360, 108},
+		{"out of a holiday", "2026-11-01 20:00", "2026-11-02 04:00", "", "", 240, 72},
+		{"all on the holiday", "2026-11-01 08:00", "2026-11-01 12:00", "", "", 240, 72},
+		{"ordinary day", "2026-10-30 22:00", "2026-10-31 06:00", "", "", 0, 0},
+		{"break on the holiday", "2026-11-01 08:00", "2026-11-01 17:00", "2026-11-01 12:00", "2026-11-01 13:00", 480, 144},
+		{"break before midnight", "2026-10-31 22:00", "2026-11-01 06:00", "2026-10-31 23:00", "2026-10-31 23:30", 360, 108},
+		{"break across midnight", "2026-10-31 22:00", "2026-11-01 06:00", "2026-10-31 23:30", "2026-11-01 01:00", 300, 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := session{In: at(t, tt.in), Out: sql.NullTime{Time: at(t, tt.out), Valid: true}}
+			if tt.brkIn != "" {
+				s.Breaks = []breakSpan{{Start: at(t, tt.brkIn), End: sql.NullTime{Time: at(t, tt.brkOut), Valid: true}}}
+			}
+			m, p := holidaySplit(s, holidays)
+			if m != tt.minutes || math.Abs(p-tt.premium) > 1e-9 {
+				t.Errorf("got %d minutes and %.2f premium, want %d and %.2f", m, p, tt.minutes, tt.premium)
+			}
+		})
+	}
+
+	// through payroll: 2 normal hours plus 6 holiday hours at 1.3
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Guard", 100)
+	addSession(t, fid, "2026-10-31 22:00", "2026-11-01 06:00")
+	if _, err := db.Exec("INSERT INTO holidays (date, type, multiplier) VALUES ('2026-11-01', 'special', 1.3)"); err != nil {
+		t.Fatal(err)
+	}
+	setVar(t, &overtimeDailyHours, 12.0)
+	setVar(t, &roundIncrement, 1)
+	r := payrollByID(t, "2026-10-31", "2026-11-01")[fid]
+	if r.HolidayHours != 6 || r.Pay != 980 {
+		t.Errorf("holiday hours %.2f pay %.2f, want 6.00 and 980.00", r.HolidayHours, r.Pay)
+	}
+
+	// a holiday day shift with an hour's lunch break: the premium is on the 8
+	// hours worked, and nothing is owed on overtime that isn't approved
+	resetDB(t)
+	fid, _ = addFaculty(t, "Ana Cruz", "Guard", 100)
+	id := addSession(t, fid, "2026-11-01 07:00", "2026-11-01 17:00")
+	if _, err := db.Exec("INSERT INTO holidays (date, type, multiplier) VALUES ('2026-11-01', 'special', 1.3)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO breaks (dtr_id, start_time, end_time) VALUES (?,?,?)",
+		id, at(t, "2026-11-01 12:00"), at(t, "2026-11-01 13:00")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name      string
+		threshold float64
+		pay       float64
+	}{
+		{"all hours paid", 12, 1170},
+		{"overtime unapproved", 6, 780},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &overtimeDailyHours, tt.threshold)
+			r := payrollByID(t, "2026-11-01", "2026-11-02")[fid]
+			if r.HolidayHours != 9 || r.Pay != tt.pay {
+				t.Errorf("holiday hours %.2f pay %.2f, want 9.00 and %.2f", r.HolidayHours, r.Pay, tt.pay)
+			}
+		})
+	}
+}
+
+func TestPayrollCSVDelimiterAndBOM(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:00")
+	tests := []struct {
+		name  string
+		delim rune
+		bom   bool
+	}{
+		{"default", ',', false},
+		{"semicolon", ';', false},
+		{"tab with BOM", '\t', true},
+		{"comma with BOM", ',', true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &csvDelimiter, tt.delim)
+			setVar(t, &csvBOM, tt.bom)
+			rec := httptest.NewRecorder()
+			handlePayrollCSV(rec, httptest.NewRequest("GET", "/payroll.csv?start=2026-10-14&end=2026-10-14", nil))
+			body := rec.Body.String()
+			if got := strings.HasPrefix(body, "\uFEFF"); got != tt.bom {
+				t.Errorf("BOM present = %v, want %v", got, tt.bom)
+			}
+			r := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\uFEFF")))
+			r.Comma = tt.delim
+			records, err := r.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 || records[0][0] != "FacultyID" || records[1][1] != "Ana Cruz" {
+				t.Errorf("records not split on %q: %q", tt.delim, records)
+			}
+		})
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    rune
+		wantErr bool
+	}{
+		{",", ',', false},
+		{";", ';', false},
+		{"tab", '\t', false},
+		{"TAB", '\t', false},
+		{"|", '|', false},
+		{`"`, 0, true},
+		{";;", 0, true},
+		{"\n", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDelimiter(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDelimiter(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSalariedPay(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions [][2]string
+		from, to string
+		hours    float64
+		pay      float64
+	}{
+		{"no attendance", nil, "2026-10-01", "2026-11-01", 0, 30000},
+		{"part time", [][2]string{{"2026-10-14 08:00", "2026-10-14 12:00"}}, "2026-10-01", "2026-11-01", 4, 30000},
+		{"long days", [][2]string{{"2026-10-14 06:00", "2026-10-14 20:00"}, {"2026-10-15 06:00", "2026-10-15 20:00"}}, "2026-10-01", "2026-11-01", 28, 30000},
+		{"half month prorated", [][2]string{{"2026-10-14 08:00", "2026-10-14 12:00"}}, "2026-10-01", "2026-10-16", 4, 14516.13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			if _, err := db.Exec("UPDATE faculty SET employment_type='salaried', monthly_salary=30000 WHERE id=?", fid); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.sessions {
+				addSession(t, fid, s[0], s[1])
+			}
+			row, ok := payrollByID(t, tt.from, tt.to)[fid]
+			if !ok {
+				t.Fatal("salaried faculty missing from payroll")
+			}
+			if row.TotalHours != tt.hours || row.Pay != tt.pay {
+				t.Errorf("got %.2fh paid %.2f, want %.2fh paid %.2f", row.TotalHours, row.Pay, tt.hours, tt.pay)
+			}
+		})
+	}
+}
+
+func TestNegativeSessions(t *testing.T) {
+	tests := []struct {
+		name     string
+		negative int
+		limit    int
+		flagged  bool
+	}{
+		{"none", 0, 0, false},
+		{"one over the default limit", 1, 0, true},
+		{"within a raised limit", 2, 2, false},
+		{"over a raised limit", 3, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &negativeSessionLimit, tt.limit)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:00")
+			for i := 0; i < tt.negative; i++ {
+				day := fmt.Sprintf("2026-10-%02d", 15+i)
+				addSession(t, fid, day+" 17:00", day+" 08:00") // out before in, as from a bad import
+			}
+			row := payrollByID(t, "2026-10-14", "2026-10-20")[fid]
+			if row.NegativeSessions != tt.negative || row.Flagged != tt.flagged {
+				t.Errorf("got %d negative, flagged %v; want %d, %v", row.NegativeSessions, row.Flagged, tt.negative, tt.flagged)
+			}
+			if row.TotalHours != 4 || row.Pay != 400 {
+				t.Errorf("got %.2fh paid %.2f, want negative sessions excluded (4h, 400)", row.TotalHours, row.Pay)
+			}
+		})
+	}
+}
+
+func TestRatePreview(t *testing.T) {
+	resetDB(t)
+	hourly, _ := addFaculty(t, "Ana Cruz", "Teacher", 120)
+	salaried, _ := addFaculty(t, "Ben Reyes", "Teacher", 0)
+	if _, err := db.Exec("UPDATE faculty SET employment_type='salaried', monthly_salary=31000 WHERE id=?", salaried); err != nil {
+		t.Fatal(err)
+	}
+	for _, fid := range []int{hourly, salaried} {
+		addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:30")
+		addSession(t, fid, "2026-10-15 08:00", "2026-10-15 11:00")
+	}
+	stored := payrollByID(t, "2026-10-01", "2026-11-01")
+
+	tests := []struct {
+		name string
+		id   int
+		rate float64
+		want float64
+	}{
+		{"raise", hourly, 150, stored[hourly].Pay * 150 / 120},
+		{"cut", hourly, 90, stored[hourly].Pay * 90 / 120},
+		{"same rate", hourly, 120, stored[hourly].Pay},
+		{"salaried ignores the rate", salaried, 500, stored[salaried].Pay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleRatePreview(rec, httptest.NewRequest("GET", fmt.Sprintf("/faculty/rate-preview?id=%d&rate=%g&start=2026-10-01&end=2026-10-31", tt.id, tt.rate), nil))
+			if rec.Code != 200 {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var got struct {
+				CurrentPay float64 `json:"current_pay"`
+				PreviewPay float64 `json:"preview_pay"`
+				Difference float64 `json:"difference"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.CurrentPay != stored[tt.id].Pay || math.Abs(got.PreviewPay-tt.want) > 0.005 {
+				t.Errorf("got current %.2f preview %.2f, want %.2f and %.2f", got.CurrentPay, got.PreviewPay, stored[tt.id].Pay, tt.want)
+			}
+			if math.Abs(got.Difference-(got.PreviewPay-got.CurrentPay)) > 0.005 {
+				t.Errorf("difference %.2f does not match", got.Difference)
+			}
+		})
+	}
+	var rate float64
+	if err := db.QueryRow("SELECT rate_per_hour FROM faculty WHERE id=?", hourly).Scan(&rate); err != nil || rate != 120 {
+		t.Errorf("stored rate changed to %v (%v)", rate, err)
+	}
+
+	for _, q := range []struct {
+		query string
+		code  int
+	}{
+		{"?id=999999&rate=100&start=2026-10-01&end=2026-10-31", http.StatusNotFound},
+		{fmt.Sprintf("?id=%d&rate=-1&start=2026-10-01&end=2026-10-31", hourly), http.StatusBadRequest},
+		{"?rate=100&start=2026-10-01&end=2026-10-31", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handleRatePreview(rec, httptest.NewRequest("GET", "/faculty/rate-preview"+q.query, nil))
+		if rec.Code != q.code {
+			t.Errorf("%s: got %d, want %d", q.query, rec.Code, q.code)
+		}
+	}
+}
+
+func TestPayrollDetailCSV(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	closed := addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:00")
+	negative := addSession(t, fid, "2026-10-15 17:00", "2026-10-15 08:00")
+	open := addSession(t, fid, "2026-10-16 08:00", "")
+	// outside the range
+	addSession(t, fid, "2026-10-20 08:00", "2026-10-20 12:00")
+
+	rec := httptest.NewRecorder()
+	handlePayrollDetailCSV(rec, httptest.NewRequest("GET", "/payroll-detail.csv?start=2026-10-14&end=2026-10-16", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d rows, want a header and 3 sessions", len(records))
+	}
+	byID := map[string][]string{}
+	for _, r := range records[1:] {
+		byID[r[0]] = r
+	}
+	tests := []struct {
+		name             string
+		id               int
+		out, flag, hours string
+	}{
+		{"closed", closed, "2026-10-14 12:00:00", "", "4.00"},
+		{"out before in", negative, "2026-10-15 08:00:00", "out before in", "0.00"},
+		{"open", open, "", "open", "0.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := byID[fmt.Sprint(tt.id)]
+			if !ok {
+				t.Fatal("session missing from the detail CSV")
+			}
+			if r[4] != tt.out || r[5] != tt.flag || r[6] != tt.hours {
+				t.Errorf("got out %q flag %q hours %q, want %q %q %q", r[4], r[5], r[6], tt.out, tt.flag, tt.hours)
+			}
+		})
+	}
+}
+
+func TestDayRatePay(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions [][2]string
+		minHours float64
+		days     int
+		pay      float64
+	}{
+		{"three full days", [][2]string{
+			{"2026-10-12 08:00", "2026-10-12 17:00"},
+			{"2026-10-13 08:00", "2026-10-13 12:00"},
+			{"2026-10-14 13:00", "2026-10-14 20:00"},
+		}, 0, 3, 2400},
+		{"split sessions count one day", [][2]string{
+			{"2026-10-12 08:00", "2026-10-12 12:00"},
+			{"2026-10-12 13:00", "2026-10-12 17:00"},
+		}, 0, 1, 800},
+		{"short day below the minimum", [][2]string{
+			{"2026-10-12 08:00", "2026-10-12 17:00"},
+			{"2026-10-13 08:00", "2026-10-13 10:00"},
+		}, 4, 1, 800},
+		{"no attendance", nil, 4, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &dayRateMinHours, tt.minHours)
+			fid, _ := addFaculty(t, "Ana Cruz", "Guard", 800)
+			if _, err := db.Exec("UPDATE faculty SET rate_unit='day' WHERE id=?", fid); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.sessions {
+				addSession(t, fid, s[0], s[1])
+			}
+			row := payrollByID(t, "2026-10-12", "2026-10-17")[fid]
+			if row.DaysWorked != tt.days || row.Pay != tt.pay || row.Pay != float64(row.DaysWorked)*row.RatePerHour {
+				t.Errorf("got %d days paid %.2f, want %d days × 800 = %.2f", row.DaysWorked, row.Pay, tt.days, tt.pay)
+			}
+		})
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		round string
+		v     float64
+		want  string
+	}{
+		{"centavo", 1234.5, "₱1234.50"},
+		{"centavo", 0.125, "₱0.13"},
+		{"peso", 1234.5, "₱1235.00"},
+		{"peso", 1234.49, "₱1234.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.round+" "+tt.want, func(t *testing.T) {
+			setVar(t, &moneyRound, tt.round)
+			if got := formatMoney(tt.v); got != tt.want {
+				t.Errorf("formatMoney(%v) = %s, want %s", tt.v, got, tt.want)
+			}
+			// the payroll page and exports round the same way
+			rows := []payrollRow{{Pay: tt.v}}
+			presentPay(rows)
+			if got := fmt.Sprintf("₱%.2f", rows[0].Pay); got != tt.want {
+				t.Errorf("payroll shows %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ---------- PAY PERIODS ----------
+
+// A pay period moves open → locked → paid. A locked period can be reopened
+// to fix records; a paid period is final.
+var periodTransitions = map[string][]string{
+	"open":   {"locked"},
+	"locked": {"open", "paid"},
+	"paid":   nil,
+}
+
+// payPeriod is an inclusive range of days. dtr records inside a locked or
+// paid period are frozen because payroll for it has been finalized.
+type payPeriod struct {
+	ID     int
+	Start  string
+	End    string
+	Status string
+}
+
+// errPeriodLocked reports a dtr change that falls inside a locked period.
+type errPeriodLocked struct{ Period payPeriod }
+
+func (e errPeriodLocked) Error() string {
+	return fmt.Sprintf("pay period %s to %s is %s", e.Period.Start, e.Period.End, e.Period.Status)
+}
+
+// errBadTransition reports a status change periodTransitions doesn't allow.
+type errBadTransition struct{ From, To string }
+
+func (e errBadTransition) Error() string {
+	return fmt.Sprintf("a %s period cannot be made %s", e.From, e.To)
+}
+
+// checkUnlocked returns errPeriodLocked if any day from in through out is
+// inside a locked or paid period, so a session can't be clocked out, or
+// stretched, into a finalized period. An open session checks its in day alone
+// and an out-only row its out day alone.
+func checkUnlocked(in, out sql.NullTime) error {
+	if !in.Valid {
+		in = out
+	}
+	if !out.Valid {
+		out = in
+	}
+	if !in.Valid {
+		return nil
+	}
+	first := in.Time.In(appLoc).Format("2006-01-02")
+	last := out.Time.In(appLoc).Format("2006-01-02")
+	var p payPeriod
+	err := db.QueryRow("SELECT id, start_date, end_date, status FROM locked_periods WHERE status != 'open' AND start_date <= ? AND end_date >= ? ORDER BY start_date LIMIT 1", last, first).
+		Scan(&p.ID, &p.Start, &p.End, &p.Status)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return errPeriodLocked{p}
+}
+
+func listPayPeriods() ([]payPeriod, error) {
+	rs, err := db.Query("SELECT id, start_date, end_date, status FROM locked_periods ORDER BY start_date DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []payPeriod
+	for rs.Next() {
+		var p payPeriod
+		if err := rs.Scan(&p.ID, &p.Start, &p.End, &p.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rs.Err()
+}
+
+// currentPeriod returns the period containing today, or nil if there is none.
+func currentPeriod() (*payPeriod, error) {
+	day := appNow().Format("2006-01-02")
+	var p payPeriod
+	err := db.QueryRow("SELECT id, start_date, end_date, status FROM locked_periods WHERE ? BETWEEN start_date AND end_date ORDER BY start_date DESC LIMIT 1", day).
+		Scan(&p.ID, &p.Start, &p.End, &p.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// transitionPeriod moves period id to status to, returning errBadTransition
+// if periodTransitions doesn't allow it and sql.ErrNoRows if there's no such
+// period.
+func transitionPeriod(id, to string) (payPeriod, error) {
+	var p payPeriod
+	if err := db.QueryRow("SELECT id, start_date, end_date, status FROM locked_periods WHERE id=?", id).
+		Scan(&p.ID, &p.Start, &p.End, &p.Status); err != nil {
+		return p, err
+	}
+	allowed := false
+	for _, s := range periodTransitions[p.Status] {
+		allowed = allowed || s == to
+	}
+	if !allowed {
+		return p, errBadTransition{p.Status, to}
+	}
+	// the status guard keeps two concurrent transitions from both succeeding
+	res, err := db.Exec("UPDATE locked_periods SET status=? WHERE id=? AND status=?", to, id, p.Status)
+	if err != nil {
+		return p, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return p, fmt.Errorf("pay period %s changed concurrently, try again", id)
+	}
+	p.Status = to
+	return p, nil
+}
+
+// handlePeriodCreate adds an open pay period; it must not overlap another.
+func handlePeriodCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	start, err1 := time.ParseInLocation("2006-01-02", r.FormValue("start"), appLoc)
+	end, err2 := time.ParseInLocation("2006-01-02", r.FormValue("end"), appLoc)
+	if err1 != nil || err2 != nil || end.Before(start) {
+		http.Error(w, "Invalid period, expected start <= end as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	s, e := start.Format("2006-01-02"), end.Format("2006-01-02")
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM locked_periods WHERE start_date <= ? AND end_date >= ?", e, s).Scan(&n); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if n > 0 {
+		http.Error(w, "Period overlaps an existing pay period", http.StatusConflict)
+		return
+	}
+	if _, err := db.Exec("INSERT INTO locked_periods (start_date, end_date, status) VALUES (?, ?, 'open')", s, e); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "period.create", s+" to "+e, "")
+	setFlash(w, r, fmt.Sprintf("Opened pay period %s to %s.", s, e))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handlePeriodTransition returns a handler that moves the posted period id
+// to status to.
+func handlePeriodTransition(to string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", 400)
+			return
+		}
+		id := r.FormValue("id")
+		if id == "" {
+			http.Error(w, "Missing id", http.StatusBadRequest)
+			return
+		}
+		p, err := transitionPeriod(id, to)
+		switch err.(type) {
+		case nil:
+		case errBadTransition:
+			http.Error(w, "Cannot change pay period: "+err.Error(), http.StatusConflict)
+			return
+		default:
+			if err == sql.ErrNoRows {
+				http.Error(w, "Pay period not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit(r, "period."+to, p.Start+" to "+p.End, "")
+		setFlash(w, r, fmt.Sprintf("Pay period %s to %s is now %s.", p.Start, p.End, to))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func postPeriod(t *testing.T, h http.HandlerFunc, form url.Values) int {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/periods", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec.Code
+}
+
+func TestPeriodTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     int
+	}{
+		{"open", "locked", http.StatusSeeOther},
+		{"locked", "open", http.StatusSeeOther},
+		{"locked", "paid", http.StatusSeeOther},
+		{"open", "paid", http.StatusConflict},
+		{"open", "open", http.StatusConflict},
+		{"paid", "open", http.StatusConflict},
+		{"paid", "locked", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			resetDB(t)
+			res, err := db.Exec("INSERT INTO locked_periods (start_date, end_date, status) VALUES ('2026-10-01', '2026-10-15', ?)", tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, _ := res.LastInsertId()
+			if code := postPeriod(t, handlePeriodTransition(tt.to), url.Values{"id": {strconv.FormatInt(id, 10)}}); code != tt.want {
+				t.Fatalf("got %d, want %d", code, tt.want)
+			}
+			want := tt.from
+			if tt.want == http.StatusSeeOther {
+				want = tt.to
+			}
+			var status string
+			if err := db.QueryRow("SELECT status FROM locked_periods WHERE id=?", id).Scan(&status); err != nil {
+				t.Fatal(err)
+			}
+			if status != want {
+				t.Errorf("status is %s, want %s", status, want)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action=?", "period."+tt.to); (n == 1) != (tt.want == http.StatusSeeOther) {
+				t.Errorf("%d audit entries for the transition", n)
+			}
+		})
+	}
+
+	if code := postPeriod(t, handlePeriodTransition("locked"), url.Values{"id": {"999999"}}); code != http.StatusNotFound {
+		t.Errorf("unknown period: got %d, want 404", code)
+	}
+}
+
+func TestPeriodCreate(t *testing.T) {
+	resetDB(t)
+	tests := []struct {
+		name       string
+		start, end string
+		want       int
+	}{
+		{"first period", "2026-10-01", "2026-10-15", http.StatusSeeOther},
+		{"next period", "2026-10-16", "2026-10-31", http.StatusSeeOther},
+		{"overlapping", "2026-10-10", "2026-10-20", http.StatusConflict},
+		{"end before start", "2026-11-15", "2026-11-01", http.StatusBadRequest},
+		{"bad date", "Nov 1", "2026-11-15", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := postPeriod(t, handlePeriodCreate, url.Values{"start": {tt.start}, "end": {tt.end}}); code != tt.want {
+				t.Errorf("got %d, want %d", code, tt.want)
+			}
+		})
+	}
+	if n := count(t, "SELECT COUNT(*) FROM locked_periods WHERE status='open'"); n != 2 {
+		t.Errorf("%d open periods, want 2", n)
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ---------- QR MAINTENANCE ----------
+
+// orphanQRFiles lists PNGs in qrDir whose token no longer belongs to any faculty.
+func orphanQRFiles() ([]string, error) {
+	tokens := map[string]bool{}
+	rs, err := db.Query("SELECT token FROM faculty")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var t string
+		if err := rs.Scan(&t); err != nil {
+			return nil, err
+		}
+		tokens[t] = true
+	}
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(qrDir)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".png" {
+			continue
+		}
+		if !tokens[strings.TrimSuffix(name, ".png")] {
+			orphans = append(orphans, name)
+		}
+	}
+	return orphans, nil
+}
+
+// handleCleanupQRs removes orphaned QR images. Without confirm=true it only
+// reports what would be removed.
+func handleCleanupQRs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	orphans, err := orphanQRFiles()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	dryRun := r.FormValue("confirm") != "true"
+	removed := 0
+	if !dryRun {
+		for _, name := range orphans {
+			if err := os.Remove(filepath.Join(qrDir, name)); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			removed++
+		}
+		audit(r, "qr.cleanup", qrDir, fmt.Sprintf("removed %d orphaned QR files", removed))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		DryRun  bool     `json:"dry_run"`
+		Orphans []string `json:"orphans"`
+		Removed int      `json:"removed"`
+	}{dryRun, orphans, removed})
+}
+
+// qrMu serializes bulk regenerations with each other and with faculty adds
+// and deletes, so a regeneration never writes a card for a faculty deleted
+// after it listed them.
+var qrMu sync.Mutex
+
+// handleRegenerateAllQRs rewrites every faculty's QR with the current payload
+// settings, e.g. after BASE_URL changes.
+func handleRegenerateAllQRs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	qrMu.Lock()
+	defer qrMu.Unlock()
+
+	type faculty struct{ token, name, role string }
+	rs, err := db.Query("SELECT token, name, role FROM faculty")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var all []faculty
+	for rs.Next() {
+		var f faculty
+		if err := rs.Scan(&f.token, &f.name, &f.role); err != nil {
+			rs.Close()
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		all = append(all, f)
+	}
+	rs.Close()
+
+	written := 0
+	failed := []string{}
+	for _, f := range all {
+		if err := writeQR(r.Host, f.token, f.name, f.role); err != nil {
+			failed = append(failed, f.token+": "+err.Error())
+			continue
+		}
+		written++
+	}
+	audit(r, "qr.regenerate-all", qrDir, fmt.Sprintf("rewrote %d of %d QR files", written, len(all)))
+
+	writeJSON(w, http.StatusOK, struct {
+		Total   int      `json:"total"`
+		Written int      `json:"written"`
+		Failed  []string `json:"failed"`
+	}{len(all), written, failed})
+}
+
+// qrFaculty is what writeQR needs, plus the base the current QR points at.
+type qrFaculty struct{ token, name, role, base string }
+
+// staleQRBases lists faculty whose QR was written for a different scheme and
+// host than BASE_URL. QRs from before the base was recorded count as stale,
+// since where they point is unknown. Without BASE_URL there is nothing to
+// compare against and nothing is stale.
+func staleQRBases() ([]qrFaculty, error) {
+	if baseURL == "" {
+		return nil, nil
+	}
+	rs, err := db.Query("SELECT token, name, role, qr_base FROM faculty WHERE qr_base != ?", baseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []qrFaculty
+	for rs.Next() {
+		var f qrFaculty
+		if err := rs.Scan(&f.token, &f.name, &f.role, &f.base); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rs.Err()
+}
+
+// checkQRBase logs a warning at startup when QRs point somewhere other than
+// BASE_URL, e.g. after the server moved; the index offers the fix.
+func checkQRBase() {
+	stale, err := staleQRBases()
+	if err != nil {
+		log.Printf("QR base check: %v", err)
+		return
+	}
+	if len(stale) == 0 {
+		return
+	}
+	sample := stale[0].base
+	if sample == "" {
+		sample = "unknown"
+	}
+	log.Printf("WARNING: %d QR codes do not point at BASE_URL %s (e.g. %s); POST /admin/qr/fix-base-url to rewrite them",
+		len(stale), baseURL, sample)
+}
+
+// handleFixQRBase rewrites just the QRs staleQRBases reports.
+func handleFixQRBase(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	qrMu.Lock()
+	defer qrMu.Unlock()
+
+	stale, err := staleQRBases()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	written := 0
+	for _, f := range stale {
+		if err := writeQR(r.Host, f.token, f.name, f.role); err != nil {
+			logf(r, "fix QR base for %s: %v", f.token, err)
+			continue
+		}
+		written++
+	}
+	audit(r, "qr.fix-base-url", baseURL, fmt.Sprintf("rewrote %d of %d QR files", written, len(stale)))
+	msg := fmt.Sprintf("Rewrote %d QR codes to point at %s; reprint their cards.", written, baseURL)
+	if written < len(stale) {
+		msg += fmt.Sprintf(" %d failed, see the server log.", len(stale)-written)
+	}
+	setFlash(w, r, msg)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// missingQRFiles lists faculty whose QR PNG is not in qrDir, e.g. after the
+// folder was lost or restored from an older backup.
+func missingQRFiles() ([]qrFaculty, error) {
+	rs, err := db.Query("SELECT token, name, role, qr_base FROM faculty ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []qrFaculty
+	for rs.Next() {
+		var f qrFaculty
+		if err := rs.Scan(&f.token, &f.name, &f.role, &f.base); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(qrFile(f.token)); os.IsNotExist(err) {
+			out = append(out, f)
+		}
+	}
+	return out, rs.Err()
+}
+
+// handleMissingQRs lists the faculty missingQRFiles reports.
+func handleMissingQRs(w http.ResponseWriter, r *http.Request) {
+	missing, err := missingQRFiles()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	type entry struct {
+		Name  string `json:"name"`
+		Role  string `json:"role"`
+		Token string `json:"token"`
+	}
+	out := []entry{}
+	for _, f := range missing {
+		out = append(out, entry{f.name, f.role, f.token})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleRegenerateMissingQRs writes just the QRs missingQRFiles reports.
+func handleRegenerateMissingQRs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	qrMu.Lock()
+	defer qrMu.Unlock()
+
+	missing, err := missingQRFiles()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	written := 0
+	for _, f := range missing {
+		if err := writeQR(r.Host, f.token, f.name, f.role); err != nil {
+			logf(r, "regenerate missing QR for %s: %v", f.token, err)
+			continue
+		}
+		written++
+	}
+	audit(r, "qr.regenerate-missing", qrDir, fmt.Sprintf("wrote %d of %d missing QR files", written, len(missing)))
+	msg := fmt.Sprintf("Regenerated %d missing QR codes.", written)
+	if written < len(missing) {
+		msg += fmt.Sprintf(" %d failed, see the server log.", len(missing)-written)
+	}
+	setFlash(w, r, msg)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// zipNameUnsafe matches characters replaced in ZIP entry names.
+var zipNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// handleQRZip streams every active faculty's QR PNG as a ZIP, named
+// "<name>-<token>.png". Missing PNGs are generated first.
+func handleQRZip(w http.ResponseWriter, r *http.Request) {
+	type faculty struct{ token, name, role string }
+	rs, err := db.Query("SELECT token, name, role FROM faculty WHERE active=1 ORDER BY name")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var all []faculty
+	for rs.Next() {
+		var f faculty
+		if err := rs.Scan(&f.token, &f.name, &f.role); err != nil {
+			rs.Close()
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		all = append(all, f)
+	}
+	rs.Close()
+
+	// generate before streaming so a failure can still be reported as an error
+	for _, f := range all {
+		if _, err := os.Stat(qrFile(f.token)); os.IsNotExist(err) {
+			if err := writeQR(r.Host, f.token, f.name, f.role); err != nil {
+				http.Error(w, "Failed to generate QR: "+err.Error(), 500)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment;filename=qrs.zip")
+	zw := zip.NewWriter(w)
+	for _, f := range all {
+		png, err := os.ReadFile(qrFile(f.token))
+		if err != nil {
+			logf(r, "qrs.zip: %v", err)
+			continue
+		}
+		name := strings.Trim(zipNameUnsafe.ReplaceAllString(f.name, "_"), "_") + "-" + f.token + ".png"
+		fw, err := zw.Create(name)
+		if err != nil {
+			logf(r, "qrs.zip: %v", err)
+			return
+		}
+		fw.Write(png)
+	}
+	if err := zw.Close(); err != nil {
+		logf(r, "qrs.zip: %v", err)
+	}
+}
+
+// handleQRPayloadsCSV lists each active faculty's QR payload, exactly as
+// writeQR would encode it now, for card printers that render their own QR.
+// Payloads span several lines and are quoted accordingly.
+func handleQRPayloadsCSV(w http.ResponseWriter, r *http.Request) {
+	rs, err := db.Query("SELECT name, role, token FROM faculty WHERE active=1 ORDER BY name")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer rs.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment;filename=qr-payloads.csv")
+	csvw := csv.NewWriter(w)
+	defer csvw.Flush()
+	csvw.Write([]string{"Name", "Role", "Token", "Payload"})
+	for rs.Next() {
+		var name, role, token string
+		if err := rs.Scan(&name, &role, &token); err != nil {
+			logf(r, "payloads.csv: %v", err)
+			return
+		}
+		csvw.Write([]string{name, role, token, qrPayload(r.Host, token, name, role)})
+	}
+}
+
+// ---------- QR CARD PREVIEW ----------
+
+// QR card geometry in millimetres, shared by the printed PDF and the HTML preview.
+const (
+	cardW       = 60.0
+	cardH       = 70.0 // taller to fit QR under text
+	cardNameY   = 8.0
+	cardRoleY   = 14.0
+	cardQRY     = 25.0
+	cardQRSize  = 28.0
+	cardLogoGap = 3.0
+
+	// optional Code-128 of the token under the QR
+	cardBarcodeW = 48.0
+	cardBarcodeH = 8.0
+)
+
+// paperMM is the portrait width and height in millimetres of each paper size
+// /print-qrs.pdf accepts, keyed by its gofpdf name.
+var paperMM = map[string][2]float64{
+	"A4":     {210, 297},
+	"Letter": {215.9, 279.4},
+	"Legal":  {215.9, 355.6},
+}
+
+// Auto-fit keeps at least these clearances so cards stay cuttable.
+const (
+	fitMinMargin  = 8.0
+	fitMinSpacing = 4.0
+)
+
+// cardGrid places cards on a page; spacing spreads the leftover space evenly.
+type cardGrid struct {
+	Orientation        string // "P" or "L"
+	Cols, Rows         int
+	MarginX, MarginY   float64
+	SpacingX, SpacingY float64
+}
+
+// fitCardGrid picks the orientation and grid that fit the most cards of
+// w×h mm on paper, then centres the grid by spreading the spare room over
+// the gaps. At least one card always fits, even if it overflows the page.
+func fitCardGrid(paper string, w, h float64) cardGrid {
+	fit := func(avail, size float64) int {
+		n := int((avail - 2*fitMinMargin + fitMinSpacing) / (size + fitMinSpacing))
+		if n < 1 {
+			n = 1
+		}
+		return n
+	}
+	spread := func(avail, size float64, n int) (margin, spacing float64) {
+		spacing = fitMinSpacing
+		if n > 1 {
+			spacing = (avail - 2*fitMinMargin - float64(n)*size) / float64(n-1)
+		}
+		// cap gaps at the margin so a short row doesn't stretch across the page
+		if spacing > 2*fitMinMargin {
+			spacing = 2 * fitMinMargin
+		}
+		margin = (avail - float64(n)*size - float64(n-1)*spacing) / 2
+		return margin, spacing
+	}
+
+	pw, ph := paperMM[paper][0], paperMM[paper][1]
+	best := cardGrid{}
+	for _, o := range []string{"P", "L"} {
+		pageW, pageH := pw, ph
+		if o == "L" {
+			pageW, pageH = ph, pw
+		}
+		g := cardGrid{Orientation: o, Cols: fit(pageW, w), Rows: fit(pageH, h)}
+		if g.Cols*g.Rows <= best.Cols*best.Rows {
+			continue
+		}
+		g.MarginX, g.SpacingX = spread(pageW, w, g.Cols)
+		g.MarginY, g.SpacingY = spread(pageH, h, g.Rows)
+		best = g
+	}
+	return best
+}
+
+// handleCardPreview renders one faculty's QR card in HTML at print size so
+// the layout can be checked without generating the PDF.
+func handleCardPreview(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		branding
+		ID                                      string
+		Name, Role, Token                       string
+		CardW, CardH, NameY, RoleY, QRY, QRSize float64
+		LogoY, LogoH                            float64
+	}
+	data.branding = pageBranding()
+	data.ID = r.FormValue("id")
+	err := db.QueryRow("SELECT name, role, token FROM faculty WHERE id=?", data.ID).Scan(&data.Name, &data.Role, &data.Token)
+	if err != nil {
+		http.Error(w, "Faculty not found", http.StatusNotFound)
+		return
+	}
+	data.CardW, data.CardH = cardW, cardH
+	data.NameY, data.RoleY = cardNameY, cardRoleY
+	data.QRY, data.QRSize = cardQRY, cardQRSize
+	data.LogoY, data.LogoH = cardQRY+cardQRSize+cardLogoGap, logoHeightMM
+	tplCard.Execute(w, data)
+}
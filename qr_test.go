@@ -0,0 +1,645 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+func TestCleanupQRs(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirm    string
+		wantOrphan bool
+		body       string
+	}{
+		{"dry run", "", true, `"dry_run":true`},
+		{"not confirmed", "yes", true, `"dry_run":true`},
+		{"confirmed", "true", false, `"removed":1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			for _, f := range []string{filepath.Join(qrDir, token+".png"), filepath.Join(qrDir, "deletedfaculty.png")} {
+				if err := os.WriteFile(f, []byte("png"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rec := httptest.NewRecorder()
+			handleCleanupQRs(rec, httptest.NewRequest("POST", "/admin/cleanup-qrs?confirm="+tt.confirm, nil))
+			if rec.Code != 200 || !strings.Contains(rec.Body.String(), tt.body) || !strings.Contains(rec.Body.String(), "deletedfaculty.png") {
+				t.Fatalf("got %d %s", rec.Code, rec.Body)
+			}
+			if _, err := os.Stat(filepath.Join(qrDir, "deletedfaculty.png")); (err == nil) != tt.wantOrphan {
+				t.Errorf("orphan exists = %v, want %v", err == nil, tt.wantOrphan)
+			}
+			if _, err := os.Stat(filepath.Join(qrDir, token+".png")); err != nil {
+				t.Errorf("a live faculty's QR was removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegenerateAllQRs(t *testing.T) {
+	resetDB(t)
+	setVar(t, &baseURL, "https://dtr.example.edu")
+	type card struct{ name, role, token string }
+	var cards []card
+	for _, f := range []struct{ name, role string }{{"Ana Cruz", "Teacher"}, {"Ben Reyes", "Staff"}, {"Cora Lim", "Librarian"}} {
+		_, token := addFaculty(t, f.name, f.role, 100)
+		if err := os.WriteFile(filepath.Join(qrDir, token+".png"), []byte("old payload"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cards = append(cards, card{f.name, f.role, token})
+	}
+
+	rec := httptest.NewRecorder()
+	handleRegenerateAllQRs(rec, httptest.NewRequest("POST", "/admin/qr/regenerate-all", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"written":3`) {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	for _, c := range cards {
+		t.Run(c.name, func(t *testing.T) {
+			q, err := qrcode.New(qrPayload("", c.token, c.name, c.role), qrcode.Medium)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.ForegroundColor, q.BackgroundColor = qrForeground, qrBackground
+			q.DisableBorder = !qrQuietZone
+			want, _ := q.PNG(256)
+			got, err := os.ReadFile(filepath.Join(qrDir, c.token+".png"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("QR file was not rewritten with the current payload")
+			}
+			if n := count(t, "SELECT COUNT(*) FROM faculty WHERE token=? AND qr_base=?", c.token, baseURL); n != 1 {
+				t.Error("qr_base not updated")
+			}
+		})
+	}
+}
+
+func TestCardPreview(t *testing.T) {
+	resetDB(t)
+	fid, token := addFaculty(t, "Ana <Cruz>", "Teacher", 100)
+	tests := []struct {
+		name     string
+		id       string
+		want     int
+		contains []string
+	}{
+		{"known faculty", strconv.Itoa(fid), http.StatusOK, []string{"Ana &lt;Cruz&gt;", "Teacher", `src="/qrs/` + token + `.png"`}},
+		{"unknown faculty", "999999", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleCardPreview(rec, httptest.NewRequest("GET", "/faculty/card-preview?id="+tt.id, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(rec.Body.String(), s) {
+					t.Errorf("preview lacks %s", s)
+				}
+			}
+		})
+	}
+}
+
+func TestQRCardsPDFBarcode(t *testing.T) {
+	resetDB(t)
+	setVar(t, &logoImageType, "")
+	var cards []qrCard
+	for _, name := range []string{"Ana Cruz", "Ben Reyes"} {
+		_, token := addFaculty(t, name, "Teacher", 100)
+		if err := writeQR("dtr.local", token, name, "Teacher"); err != nil {
+			t.Fatal(err)
+		}
+		cards = append(cards, qrCard{name, "Teacher", token})
+	}
+	tests := []struct {
+		name    string
+		barcode bool
+		images  int
+	}{
+		{"QR only", false, 2},
+		{"QR and barcode", true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeQRCardsPDF(&buf, cards, qrCardLayout{paper: "A4", withBarcode: tt.barcode}); err != nil {
+				t.Fatal(err)
+			}
+			if n := bytes.Count(buf.Bytes(), []byte("/Subtype /Image")); n != tt.images {
+				t.Errorf("PDF has %d images, want %d", n, tt.images)
+			}
+		})
+	}
+}
+
+func TestQRColors(t *testing.T) {
+	navy := color.RGBA{0x1f, 0x2a, 0x44, 0xff}
+	cream := color.RGBA{0xff, 0xf8, 0xe7, 0xff}
+	tests := []struct {
+		name   string
+		fg, bg color.Color
+		quiet  bool
+	}{
+		{"default", color.Black, color.White, true},
+		{"branded", navy, cream, true},
+		{"no quiet zone", navy, cream, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &qrForeground, tt.fg)
+			setVar(t, &qrBackground, tt.bg)
+			setVar(t, &qrQuietZone, tt.quiet)
+			token := "colors" + strconv.Itoa(len(tt.name))
+			if err := writeQR("example.test", token, "Ana Cruz", "Teacher"); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(filepath.Join(qrDir, token+".png"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			img, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			same := func(a, b color.Color) bool {
+				r1, g1, b1, _ := a.RGBA()
+				r2, g2, b2, _ := b.RGBA()
+				return r1 == r2 && g1 == g2 && b1 == b2
+			}
+			// the top-left corner is the quiet zone, or the finder pattern without one
+			corner := img.At(img.Bounds().Min.X, img.Bounds().Min.Y)
+			want := tt.fg
+			if tt.quiet {
+				want = tt.bg
+			}
+			if !same(corner, want) {
+				t.Errorf("corner is %v, want %v", corner, want)
+			}
+			var fg, bg bool
+			for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+				for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+					c := img.At(x, y)
+					fg = fg || same(c, tt.fg)
+					bg = bg || same(c, tt.bg)
+				}
+			}
+			if !fg || !bg {
+				t.Errorf("foreground present = %v, background present = %v", fg, bg)
+			}
+		})
+	}
+}
+
+func TestQRContrastConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		fg, bg string
+		ok     bool
+	}{
+		{"default", "", "", true},
+		{"navy on cream", "#1F2A44", "#FFF8E7", true},
+		{"grey on white", "#BBBBBB", "#FFFFFF", false},
+		{"inverted", "#FFFFFF", "#000000", false},
+		{"bad color", "navy", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// registered first so it runs after the environment is restored
+			t.Cleanup(func() { loadConfig() })
+			t.Setenv("QR_FOREGROUND", tt.fg)
+			t.Setenv("QR_BACKGROUND", tt.bg)
+			if err := loadConfig(); (err == nil) != tt.ok {
+				t.Errorf("loadConfig() = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQRZip(t *testing.T) {
+	resetDB(t)
+	_, withFile := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	_, missing := addFaculty(t, "Ben O'Reyes", "Staff", 100)
+	inactiveID, inactive := addFaculty(t, "Cora Gone", "Teacher", 100)
+	if _, err := db.Exec("UPDATE faculty SET active=0 WHERE id=?", inactiveID); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeQR("example.test", withFile, "Ana Cruz", "Teacher"); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(qrDir, missing+".png"))
+
+	rec := httptest.NewRecorder()
+	handleQRZip(rec, httptest.NewRequest("GET", "/qrs.zip", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]bool{}
+	for _, f := range zr.File {
+		entries[f.Name] = true
+	}
+	tests := []struct {
+		name  string
+		entry string
+		want  bool
+	}{
+		{"existing file", "Ana_Cruz-" + withFile + ".png", true},
+		{"generated on the fly", "Ben_O_Reyes-" + missing + ".png", true},
+		{"inactive", "Cora_Gone-" + inactive + ".png", false},
+	}
+	for _, tt := range tests {
+		if entries[tt.entry] != tt.want {
+			t.Errorf("%s: entry %q present = %v, want %v", tt.name, tt.entry, entries[tt.entry], tt.want)
+		}
+	}
+	if len(zr.File) != 2 {
+		t.Errorf("got %d entries, want one per active faculty (2)", len(zr.File))
+	}
+}
+
+func TestFitCardGrid(t *testing.T) {
+	tests := []struct {
+		paper   string
+		h       float64
+		atLeast int
+	}{
+		{"A4", cardH, 9},
+		{"Letter", cardH, 9},
+		{"Legal", cardH, 12},
+		{"A4", cardH + cardBarcodeH + cardLogoGap, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.paper+" "+strconv.FormatFloat(tt.h, 'f', 0, 64), func(t *testing.T) {
+			g := fitCardGrid(tt.paper, cardW, tt.h)
+			if g.Cols*g.Rows < tt.atLeast {
+				t.Errorf("%d×%d grid, want at least %d cards", g.Cols, g.Rows, tt.atLeast)
+			}
+			pageW, pageH := paperMM[tt.paper][0], paperMM[tt.paper][1]
+			if g.Orientation == "L" {
+				pageW, pageH = pageH, pageW
+			}
+			usedW := 2*g.MarginX + float64(g.Cols)*cardW + float64(g.Cols-1)*g.SpacingX
+			usedH := 2*g.MarginY + float64(g.Rows)*tt.h + float64(g.Rows-1)*g.SpacingY
+			if usedW > pageW+0.01 || usedH > pageH+0.01 {
+				t.Errorf("grid needs %.1f×%.1f mm on a %.1f×%.1f page", usedW, usedH, pageW, pageH)
+			}
+			if g.MarginX < fitMinMargin || g.MarginY < fitMinMargin || g.SpacingX < fitMinSpacing || g.SpacingY < fitMinSpacing {
+				t.Errorf("margins %.1f/%.1f or spacing %.1f/%.1f below the minimum", g.MarginX, g.MarginY, g.SpacingX, g.SpacingY)
+			}
+		})
+	}
+}
+
+func TestQRCardsPDFAutoFitPages(t *testing.T) {
+	resetDB(t)
+	setVar(t, &logoImageType, "")
+	g := fitCardGrid("A4", cardW, cardH)
+	perPage := g.Cols * g.Rows
+	var cards []qrCard
+	for i := 0; i < perPage+1; i++ {
+		name := "Faculty " + strconv.Itoa(i)
+		_, token := addFaculty(t, name, "Teacher", 100)
+		if err := writeQR("dtr.local", token, name, "Teacher"); err != nil {
+			t.Fatal(err)
+		}
+		cards = append(cards, qrCard{name, "Teacher", token})
+	}
+	tests := []struct {
+		cards int
+		pages int
+	}{
+		{1, 1},
+		{perPage, 1},
+		{perPage + 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.cards), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeQRCardsPDF(&buf, cards[:tt.cards], qrCardLayout{paper: "A4", autoFit: true}); err != nil {
+				t.Fatal(err)
+			}
+			if n := bytes.Count(buf.Bytes(), []byte("/Type /Page\n")); n != tt.pages {
+				t.Errorf("%d cards made %d pages, want %d", tt.cards, n, tt.pages)
+			}
+		})
+	}
+}
+
+func TestFixQRBase(t *testing.T) {
+	resetDB(t)
+	setVar(t, &baseURL, "https://dtr.example.edu")
+	tests := []struct {
+		name  string
+		base  string
+		stale bool
+	}{
+		{"current", "https://dtr.example.edu", false},
+		{"old host", "http://192.168.1.20:8080", true},
+		{"unrecorded", "", true},
+	}
+	tokens := map[string]string{}
+	for _, tt := range tests {
+		fid, token := addFaculty(t, "Faculty "+tt.name, "Teacher", 100)
+		if _, err := db.Exec("UPDATE faculty SET qr_base=? WHERE id=?", tt.base, fid); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(qrDir, token+".png"), []byte("old payload"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		tokens[tt.name] = token
+	}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	checkQRBase()
+	log.SetOutput(io.Discard)
+	if !strings.Contains(logged.String(), "WARNING: 2 QR codes do not point at BASE_URL https://dtr.example.edu") {
+		t.Errorf("mismatch not reported: %q", logged.String())
+	}
+
+	rec := httptest.NewRecorder()
+	handleFixQRBase(rec, httptest.NewRequest("POST", "/admin/qr/fix-base-url", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := os.ReadFile(filepath.Join(qrDir, tokens[tt.name]+".png"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rewritten := string(got) != "old payload"; rewritten != tt.stale {
+				t.Errorf("rewritten = %v, want %v", rewritten, tt.stale)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM faculty WHERE token=? AND qr_base=?", tokens[tt.name], baseURL); n != 1 {
+				t.Error("qr_base does not match BASE_URL")
+			}
+		})
+	}
+	if stale, err := staleQRBases(); err != nil || len(stale) != 0 {
+		t.Errorf("still stale after the fix: %v %v", stale, err)
+	}
+
+	setVar(t, &baseURL, "")
+	if stale, err := staleQRBases(); err != nil || len(stale) != 0 {
+		t.Errorf("without BASE_URL nothing is stale, got %v %v", stale, err)
+	}
+}
+
+func TestMissingQRs(t *testing.T) {
+	resetDB(t)
+	_, present := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	_, missing := addFaculty(t, "Ben Reyes", "Staff", 100)
+	if err := writeQR("example.test", present, "Ana Cruz", "Teacher"); err != nil {
+		t.Fatal(err)
+	}
+	list := func() []string {
+		rec := httptest.NewRecorder()
+		handleMissingQRs(rec, httptest.NewRequest("GET", "/admin/missing-qrs", nil))
+		var got []struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("status %d: %v", rec.Code, err)
+		}
+		var tokens []string
+		for _, g := range got {
+			tokens = append(tokens, g.Token)
+		}
+		return tokens
+	}
+
+	tests := []struct {
+		name       string
+		regenerate bool
+		want       []string
+	}{
+		{"file missing on disk", false, []string{missing}},
+		{"after regenerating", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.regenerate {
+				rec := httptest.NewRecorder()
+				handleRegenerateMissingQRs(rec, httptest.NewRequest("POST", "/admin/qr/regenerate-missing", nil))
+				if rec.Code != http.StatusSeeOther {
+					t.Fatalf("regenerate: got %d %s", rec.Code, rec.Body)
+				}
+			}
+			if got := list(); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("listed %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(qrFile(missing)); err != nil {
+		t.Errorf("missing QR was not written: %v", err)
+	}
+}
+
+func TestQRPayloadsCSV(t *testing.T) {
+	for _, base := range []string{"", "https://dtr.example.edu"} {
+		t.Run("base "+base, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &baseURL, base)
+			faculty := []struct{ name, role string }{{"Ana Cruz", "Teacher"}, {"Ben, Jr. Reyes", "Staff"}}
+			for _, f := range faculty {
+				form := url.Values{"name": {f.name}, "role": {f.role}, "rate": {"100"}}
+				req := httptest.NewRequest("POST", "http://kiosk.local/faculty/add", strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				rec := httptest.NewRecorder()
+				handleFacultyAdd(rec, req)
+				if rec.Code != http.StatusFound {
+					t.Fatalf("add %s: %d %s", f.name, rec.Code, rec.Body)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			handleQRPayloadsCSV(rec, httptest.NewRequest("GET", "http://kiosk.local/faculty/payloads.csv", nil))
+			records, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(faculty)+1 {
+				t.Fatalf("got %d rows, want a header and %d faculty", len(records), len(faculty))
+			}
+			for _, r := range records[1:] {
+				name, role, token, payload := r[0], r[1], r[2], r[3]
+				if want := qrPayload("kiosk.local", token, name, role); payload != want {
+					t.Errorf("%s: payload %q, want %q", name, payload, want)
+				}
+				// the QR written on add must encode exactly this payload
+				q, err := qrcode.New(payload, qrcode.Medium)
+				if err != nil {
+					t.Fatal(err)
+				}
+				q.ForegroundColor, q.BackgroundColor = qrForeground, qrBackground
+				q.DisableBorder = !qrQuietZone
+				want, _ := q.PNG(256)
+				got, err := os.ReadFile(qrFile(token))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: CSV payload differs from the QR written on add", name)
+				}
+			}
+		})
+	}
+}
+
+// addCardRoster adds teachers and staff with QR files, in id order.
+func addCardRoster(t *testing.T, teachers, staff int) []string {
+	t.Helper()
+	var tokens []string
+	for i := 0; i < teachers+staff; i++ {
+		name, role := "Faculty "+strconv.Itoa(i), "Teacher"
+		if i >= teachers {
+			role = "Staff"
+		}
+		_, token := addFaculty(t, name, role, 100)
+		if err := writeQR("dtr.local", token, name, role); err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func TestLoadQRCardsBatches(t *testing.T) {
+	resetDB(t)
+	setVar(t, &maxQRCards, 10)
+	tokens := addCardRoster(t, 18, 5)
+	gone, _ := addFaculty(t, "Cora Gone", "Teacher", 100)
+	if _, err := db.Exec("UPDATE faculty SET active=0 WHERE id=?", gone); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		role  string
+		sizes []int
+	}{
+		{"", []int{10, 10, 3, 0}},
+		{"staff", []int{5, 0}},
+	}
+	for _, tt := range tests {
+		t.Run("role "+tt.role, func(t *testing.T) {
+			n, err := countQRCards(tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for i, size := range tt.sizes {
+				cards, err := loadQRCards(tt.role, i+1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(cards) != size {
+					t.Errorf("batch %d has %d cards, want %d", i+1, len(cards), size)
+				}
+				for _, c := range cards {
+					got = append(got, c.token)
+				}
+			}
+			want := tokens
+			if tt.role != "" {
+				want = tokens[18:]
+			}
+			if n != len(want) || strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("counted %d, batches hold %d cards; want each of %d once in order", n, len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestPrintQRCardsBatches(t *testing.T) {
+	resetDB(t)
+	setVar(t, &logoImageType, "")
+	setVar(t, &maxQRCards, 10)
+	addCardRoster(t, 18, 5)
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		pages int // PDF pages of 9 cards
+		body  string
+	}{
+		{"roster over the cap", "", http.StatusBadRequest, 0, "page=1 to page=3"},
+		{"first batch", "?page=1", http.StatusOK, 2, ""},
+		{"last batch", "?page=3", http.StatusOK, 1, ""},
+		{"past the last batch", "?page=4", http.StatusBadRequest, 0, "want 1 to 3"},
+		{"one role under the cap", "?role=Staff", http.StatusOK, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handlePrintQRCards(rec, httptest.NewRequest("GET", "/print-qrs.pdf"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("body lacks %q: %s", tt.body, rec.Body)
+			}
+			if tt.pages != 0 {
+				if n := bytes.Count(rec.Body.Bytes(), []byte("/Type /Page\n")); n != tt.pages {
+					t.Errorf("%d pages, want %d", n, tt.pages)
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handlePrintQRZip(rec, httptest.NewRequest("GET", "/print-qrs.zip", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("zip: status %d: %s", rec.Code, rec.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"qr-cards-001.pdf": 2, "qr-cards-002.pdf": 2, "qr-cards-003.pdf": 1}
+	if len(zr.File) != len(want) {
+		t.Fatalf("zip has %d entries, want %d", len(zr.File), len(want))
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := bytes.Count(b, []byte("/Type /Page\n")); n != want[f.Name] {
+			t.Errorf("%s: %d pages, want %d", f.Name, n, want[f.Name])
+		}
+	}
+}
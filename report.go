@@ -0,0 +1,673 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ---------- AGGREGATION ----------
+
+// session is a single dtr row; Out is invalid while the faculty is still clocked in.
+type session struct {
+	ID               int
+	FacultyID        int
+	In               time.Time
+	Out              sql.NullTime
+	OvertimeApproved bool
+	Breaks           []breakSpan
+}
+
+// daySummary is one faculty's attendance on a single calendar day.
+type daySummary struct {
+	Date     time.Time
+	FirstIn  time.Time
+	LastOut  time.Time
+	Minutes  int // whole minutes worked, summed per session, less breaks
+	Breaks   int // whole minutes of recorded breaks
+	Sessions int
+	Negative int // sessions clocked out before they were clocked in
+	Open     bool
+
+	// OvertimeApproved is set when any session of the day has its overtime
+	// approved; the day's overtime is then paid.
+	OvertimeApproved bool
+}
+
+// roundMinutes rounds whole minutes to a multiple of roundIncrement in
+// roundDir; exact halves round up under "nearest".
+func roundMinutes(m int) int {
+	return roundBy(m, roundIncrement)
+}
+
+// roundClock rounds a scan time to a multiple of roundIncrement past
+// midnight in roundDir, for ROUND_AT=scan.
+func roundClock(t time.Time) time.Time {
+	day := dayStart(t)
+	secs := int(t.Sub(day) / time.Second)
+	return day.Add(time.Duration(roundBy(secs, roundIncrement*60)) * time.Second)
+}
+
+// roundBy rounds a non-negative v to a multiple of inc in roundDir.
+func roundBy(v, inc int) int {
+	switch roundDir {
+	case "up":
+		return (v + inc - 1) / inc * inc
+	case "down":
+		return v / inc * inc
+	default:
+		return (v*2 + inc) / (inc * 2) * inc
+	}
+}
+
+// roundTotal rounds a faculty's period total like roundMinutes, except that
+// totals under roundMinTotal are zero rather than rounded up to an increment.
+func roundTotal(m int) int {
+	if m < roundMinTotal {
+		return 0
+	}
+	return roundMinutes(m)
+}
+
+// minutesToHours converts minutes to hours.
+func minutesToHours(m int) float64 {
+	return float64(m) / 60
+}
+
+// sessionHours is the paid hours of one session as payroll counts a period
+// holding just that session: whole minutes less breakMin (at least
+// breakMinutes under the span day model), rounded by roundTotal. The scan page
+// shows it so a faculty's one-session day reads the same as their payroll line.
+func sessionHours(in, out time.Time, breakMin int) float64 {
+	if !out.After(in) {
+		return 0
+	}
+	if dayModel == "span" {
+		breakMin = max(breakMin, breakMinutes)
+	}
+	m := int(out.Sub(in)/time.Minute) - breakMin
+	if m <= 0 {
+		return 0
+	}
+	return minutesToHours(roundTotal(m))
+}
+
+// dayStart returns midnight in appLoc of the day containing t.
+func dayStart(t time.Time) time.Time {
+	y, m, d := t.In(appLoc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, appLoc)
+}
+
+// querySessions loads dtr sessions whose in_time falls in [from, to), with
+// their breaks. A facultyID of 0 loads sessions for every faculty.
+func querySessions(from, to time.Time, facultyID int) ([]session, error) {
+	q := "SELECT id, faculty_id, in_time, out_time, COALESCE(overtime_approved, 0) FROM dtr WHERE in_time >= ? AND in_time < ?"
+	args := []any{from, to}
+	if facultyID != 0 {
+		q += " AND faculty_id = ?"
+		args = append(args, facultyID)
+	}
+	q += " ORDER BY in_time"
+
+	rs, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var out []session
+	for rs.Next() {
+		var s session
+		if err := rs.Scan(&s.ID, &s.FacultyID, &s.In, &s.Out, &s.OvertimeApproved); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+	rs.Close()
+	return out, loadBreaks(out)
+}
+
+// summarizeDays groups sessions of one faculty by the local day of their clock-in.
+// The map is keyed by "2006-01-02". Minutes follow dayModel.
+func summarizeDays(sessions []session) map[string]*daySummary {
+	days := map[string]*daySummary{}
+	for _, s := range sessions {
+		in := s.In.In(appLoc)
+		key := in.Format("2006-01-02")
+		d, ok := days[key]
+		if !ok {
+			d = &daySummary{Date: dayStart(in), FirstIn: in}
+			days[key] = d
+		}
+		d.Sessions++
+		d.OvertimeApproved = d.OvertimeApproved || s.OvertimeApproved
+		if in.Before(d.FirstIn) {
+			d.FirstIn = in
+		}
+		if !s.Out.Valid {
+			d.Open = true
+			continue
+		}
+		out := s.Out.Time.In(appLoc)
+		if out.Before(in) {
+			// bad data (usually an import); counted so it can be reported, never paid
+			d.Negative++
+			continue
+		}
+		if out.After(d.LastOut) {
+			d.LastOut = out
+		}
+		// whole minutes per session keep totals exact however many sessions there are
+		b := s.minutesOnBreak(in, out)
+		d.Breaks += b
+		d.Minutes += int(out.Sub(in)/time.Minute) - b
+	}
+	if dayModel == "span" {
+		for _, d := range days {
+			d.Minutes = spanMinutes(d)
+		}
+	}
+	return days
+}
+
+// spanMinutes is the whole minutes from first in to last out less the day's
+// recorded breaks or breakMinutes, whichever is longer, or 0 when the day has
+// no clock-out yet.
+func spanMinutes(d *daySummary) int {
+	if d.LastOut.IsZero() || !d.LastOut.After(d.FirstIn) {
+		return 0
+	}
+	m := int(d.LastOut.Sub(d.FirstIn)/time.Minute) - max(d.Breaks, breakMinutes)
+	if m < 0 {
+		return 0
+	}
+	return m
+}
+
+// ---------- DAILY ATTENDANCE ----------
+
+type dayReportRow struct {
+	FacultyID int
+	Name      string
+	Role      string
+	FirstIn   string
+	LastOut   string
+	Hours     float64
+	Sessions  int
+	Present   bool
+	Scheduled bool
+	Notes     string
+}
+
+// Status is "present", "absent" for a scheduled day without records, or
+// "day off" for an unscheduled day without records.
+func (r dayReportRow) Status() string {
+	switch {
+	case r.Present:
+		return "present"
+	case r.Scheduled:
+		return "absent"
+	default:
+		return "day off"
+	}
+}
+
+// dayReport lists every active faculty with their attendance on the given day.
+func dayReport(day time.Time) ([]dayReportRow, error) {
+	from := dayStart(day)
+	sessions, err := querySessions(from, from.AddDate(0, 0, 1), 0)
+	if err != nil {
+		return nil, err
+	}
+	byFaculty := map[int][]session{}
+	for _, s := range sessions {
+		byFaculty[s.FacultyID] = append(byFaculty[s.FacultyID], s)
+	}
+
+	rs, err := db.Query("SELECT id, name, role, work_days, notes FROM faculty WHERE active=1 ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var rows []dayReportRow
+	for rs.Next() {
+		var row dayReportRow
+		var workDays int
+		if err := rs.Scan(&row.FacultyID, &row.Name, &row.Role, &workDays, &row.Notes); err != nil {
+			return nil, err
+		}
+		row.Scheduled = worksOn(workDays, from.Weekday())
+		for _, d := range summarizeDays(byFaculty[row.FacultyID]) {
+			row.Present = true
+			row.Sessions = d.Sessions
+			row.Hours = minutesToHours(roundMinutes(d.Minutes))
+			row.FirstIn = d.FirstIn.Format("15:04")
+			if !d.LastOut.IsZero() {
+				row.LastOut = d.LastOut.Format("15:04")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, rs.Err()
+}
+
+// parseDayParam parses a YYYY-MM-DD query value in local time, defaulting to today.
+func parseDayParam(s string) (time.Time, error) {
+	if s == "" {
+		return dayStart(appNow()), nil
+	}
+	return time.ParseInLocation("2006-01-02", s, appLoc)
+}
+
+func handleDayReport(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDayParam(r.FormValue("date"))
+	if err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	rows, err := dayReport(day)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	present, absent := 0, 0
+	for _, row := range rows {
+		switch row.Status() {
+		case "present":
+			present++
+		case "absent":
+			absent++
+		}
+	}
+
+	data := struct {
+		branding
+		Date    string
+		Rows    []dayReportRow
+		Present int
+		Absent  int
+		DayOff  int
+	}{
+		branding: pageBranding(),
+		Date:     day.Format("2006-01-02"),
+		Rows:     rows,
+		Present:  present,
+		Absent:   absent,
+		DayOff:   len(rows) - present - absent,
+	}
+
+	tplDay.Execute(w, data)
+}
+
+func handleDayReportCSV(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDayParam(r.FormValue("date"))
+	if err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	rows, err := dayReport(day)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=attendance-%s.csv", day.Format("2006-01-02")))
+	csvw := csv.NewWriter(w)
+	defer csvw.Flush()
+
+	csvw.Write([]string{"FacultyID", "Name", "Role", "FirstIn", "LastOut", "TotalHours", "Status"})
+	for _, row := range rows {
+		csvw.Write([]string{
+			strconv.Itoa(row.FacultyID), row.Name, row.Role,
+			row.FirstIn, row.LastOut,
+			fmt.Sprintf("%.2f", row.Hours),
+			row.Status(),
+		})
+	}
+}
+
+// handleNotYetIn lists active faculty scheduled on ?date= (default today)
+// who have not clocked in yet, for the front desk to follow up.
+func handleNotYetIn(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDayParam(r.FormValue("date"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+	rows, err := dayReport(day)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type missing struct {
+		FacultyID int    `json:"faculty_id"`
+		Name      string `json:"name"`
+		Role      string `json:"role"`
+	}
+	out := []missing{}
+	for _, row := range rows {
+		if row.Scheduled && !row.Present {
+			out = append(out, missing{row.FacultyID, row.Name, row.Role})
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// ---------- MONTHLY TIMESHEET ----------
+
+// monthlyHours totals one faculty's hours for each month of year, index 0
+// being January. Like the timesheet, each day is rounded before summing.
+func monthlyHours(facultyID, year int) ([12]float64, error) {
+	var out [12]float64
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, appLoc)
+	sessions, err := querySessions(from, from.AddDate(1, 0, 0), facultyID)
+	if err != nil {
+		return out, err
+	}
+	var minutes [12]int
+	for _, d := range summarizeDays(sessions) {
+		minutes[d.Date.Month()-1] += roundMinutes(d.Minutes)
+	}
+	for i, m := range minutes {
+		out[i] = minutesToHours(m)
+	}
+	return out, nil
+}
+
+// payProjection estimates a faculty's pay for a whole month from the month
+// so far. Once the month is over, the projection is the actual figure.
+type payProjection struct {
+	FacultyID      int     `json:"faculty_id"`
+	Month          string  `json:"month"`
+	Estimate       bool    `json:"estimate"`
+	DaysElapsed    int     `json:"days_elapsed"`
+	DaysInMonth    int     `json:"days_in_month"`
+	HoursToDate    float64 `json:"hours_to_date"`
+	PayToDate      float64 `json:"pay_to_date"`
+	ProjectedHours float64 `json:"projected_hours"`
+	ProjectedPay   float64 `json:"projected_pay"`
+	Note           string  `json:"note"`
+}
+
+var errMonthNotStarted = errors.New("Month has not started yet; nothing to project from")
+
+// projectMonthPay totals the faculty's payroll for month (its first day) up
+// to and including today and scales it by the days in the month over the
+// days elapsed, assuming they keep their daily average so far.
+func projectMonthPay(facultyID int, month, now time.Time) (payProjection, error) {
+	end := month.AddDate(0, 1, 0)
+	p := payProjection{
+		FacultyID:   facultyID,
+		Month:       month.Format("2006-01"),
+		DaysInMonth: int(end.Sub(month).Hours()/24 + 0.5),
+	}
+	today := dayStart(now)
+	if today.Before(month) {
+		return p, errMonthNotStarted
+	}
+	to := end
+	if today.Before(end) {
+		to = today.AddDate(0, 0, 1)
+		p.Estimate = true
+	}
+	p.DaysElapsed = int(to.Sub(month).Hours()/24 + 0.5)
+
+	rows, _, err := computePayroll(month, to)
+	if err != nil {
+		return p, err
+	}
+	for _, row := range rows {
+		if row.FacultyID == facultyID {
+			p.HoursToDate, p.PayToDate = row.TotalHours, displayMoney(row.Pay)
+		}
+	}
+	scale := float64(p.DaysInMonth) / float64(p.DaysElapsed)
+	p.ProjectedHours = math.Round(p.HoursToDate*scale*100) / 100
+	p.ProjectedPay = displayMoney(p.PayToDate * scale)
+	if p.Estimate {
+		p.Note = fmt.Sprintf("Estimate: pay so far over %d of %d days, scaled to the whole month.", p.DaysElapsed, p.DaysInMonth)
+	} else {
+		p.Note = "The month is over; these are the actual figures."
+	}
+	return p, nil
+}
+
+// maxChartDays caps the range of /api/faculty/{id}/days.
+const maxChartDays = 366
+
+// dayHours is one day of a faculty's attendance for charts.
+type dayHours struct {
+	Date     string  `json:"date"`
+	Hours    float64 `json:"hours"`
+	Sessions int     `json:"sessions"`
+}
+
+// facultyDays lists one faculty's rounded hours per day for days in
+// [from, to), oldest first. Days without records are left out unless
+// zeroFill is set, in which case they appear with zero hours.
+func facultyDays(facultyID int, from, to time.Time, zeroFill bool) ([]dayHours, error) {
+	sessions, err := querySessions(from, to, facultyID)
+	if err != nil {
+		return nil, err
+	}
+	days := summarizeDays(sessions)
+	out := []dayHours{}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		d, ok := days[key]
+		switch {
+		case ok:
+			out = append(out, dayHours{key, minutesToHours(roundMinutes(d.Minutes)), d.Sessions})
+		case zeroFill:
+			out = append(out, dayHours{Date: key})
+		}
+	}
+	return out, nil
+}
+
+// handleFacultyAPI serves the per-faculty chart data under /api/faculty/{id}/:
+//
+//   - monthly?year=YYYY: a JSON array of 12 monthly hour totals; months
+//     without records are 0. year defaults to the current one.
+//   - days?start=&end=[&zero_fill=true]: a JSON array of dayHours over a
+//     payroll range, which defaults to the current pay period.
+//   - projection?month=YYYY-MM: a payProjection for the month, which
+//     defaults to the current one.
+func handleFacultyAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/faculty/"), "/")
+	if len(parts) != 2 || (parts[1] != "monthly" && parts[1] != "days" && parts[1] != "projection") {
+		writeJSONError(w, http.StatusNotFound, "No such endpoint")
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid faculty id")
+		return
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM faculty WHERE id=?", id).Scan(&n); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n == 0 {
+		writeJSONError(w, http.StatusNotFound, "Faculty not found")
+		return
+	}
+
+	if parts[1] == "days" {
+		start, end, err := parsePayrollRange(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if end.After(start.AddDate(0, 0, maxChartDays-1)) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Range is limited to %d days", maxChartDays))
+			return
+		}
+		zeroFill := false
+		if v := r.FormValue("zero_fill"); v != "" {
+			if zeroFill, err = strconv.ParseBool(v); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Invalid zero_fill, expected true or false")
+				return
+			}
+		}
+		days, err := facultyDays(id, start, end.AddDate(0, 0, 1), zeroFill)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, days)
+		return
+	}
+
+	if parts[1] == "projection" {
+		month := dayStart(appNow()).AddDate(0, 0, 1-appNow().Day())
+		if v := r.FormValue("month"); v != "" {
+			if month, err = time.ParseInLocation("2006-01", v, appLoc); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Invalid month, expected YYYY-MM")
+				return
+			}
+		}
+		p, err := projectMonthPay(id, month, appNow())
+		if err == errMonthNotStarted {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+		return
+	}
+
+	year := appNow().Year()
+	if v := r.FormValue("year"); v != "" {
+		if year, err = strconv.Atoi(v); err != nil || year < 1 || year > 9999 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid year, expected YYYY")
+			return
+		}
+	}
+	hours, err := monthlyHours(id, year)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, hours)
+}
+
+// timesheetRows lays out the timesheet grid: Day, In, Out and Hours for
+// every calendar day of the month starting at month, blank for days without
+// records, and the month's total hours.
+func timesheetRows(month time.Time, days map[string]*daySummary) ([][]string, float64) {
+	var rows [][]string
+	var total float64
+	for d := month; d.Before(month.AddDate(0, 1, 0)); d = d.AddDate(0, 0, 1) {
+		cells := []string{d.Format("02 Mon"), "", "", ""}
+		if s, ok := days[d.Format("2006-01-02")]; ok {
+			cells[1] = s.FirstIn.Format("15:04")
+			if !s.LastOut.IsZero() {
+				cells[2] = s.LastOut.Format("15:04")
+			}
+			h := minutesToHours(roundMinutes(s.Minutes))
+			cells[3] = fmt.Sprintf("%.2f", h)
+			total += h
+		}
+		rows = append(rows, cells)
+	}
+	return rows, total
+}
+
+// handleTimesheetPDF renders a signable monthly timesheet for one faculty,
+// one row per calendar day (blank when there are no records).
+func handleTimesheetPDF(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+	today := dayStart(appNow())
+	month := today.AddDate(0, 0, 1-today.Day())
+	if m := r.FormValue("month"); m != "" {
+		month, err = time.ParseInLocation("2006-01", m, appLoc)
+		if err != nil {
+			http.Error(w, "Invalid month, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var name, role string
+	err = db.QueryRow("SELECT name, role FROM faculty WHERE id=?", id).Scan(&name, &role)
+	if err != nil {
+		http.Error(w, "Faculty not found", http.StatusNotFound)
+		return
+	}
+
+	sessions, err := querySessions(month, month.AddDate(0, 1, 0), id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	days := summarizeDays(sessions)
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("Roboto", "", "fonts/Roboto-Regular.ttf")
+	pdf.AddPage()
+
+	drawLogo(pdf, 10, 10, logoHeightMM)
+	pdf.SetFont("Roboto", "", 14)
+	pdf.CellFormat(0, 8, "Daily Time Record", "", 1, "C", false, 0, "")
+	pdf.SetFont("Roboto", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%s (%s)", name, role), "", 1, "C", false, 0, "")
+	pdf.CellFormat(0, 6, month.Format("January 2006"), "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	// Day grid
+	colW := []float64{40, 45, 45, 40}
+	header := []string{"Day", "In", "Out", "Hours"}
+	pdf.SetFillColor(216, 243, 220)
+	for i, h := range header {
+		pdf.CellFormat(colW[i], 7, h, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	rows, total := timesheetRows(month, days)
+	for _, cells := range rows {
+		for i, c := range cells {
+			pdf.CellFormat(colW[i], 6, c, "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.CellFormat(colW[0]+colW[1]+colW[2], 7, "Total", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(colW[3], 7, fmt.Sprintf("%.2f", total), "1", 1, "C", false, 0, "")
+
+	// Signature area
+	pdf.Ln(14)
+	y := pdf.GetY()
+	pdf.Line(20, y, 90, y)
+	pdf.Line(120, y, 190, y)
+	pdf.SetXY(20, y+1)
+	pdf.CellFormat(70, 5, "Employee Signature", "", 0, "C", false, 0, "")
+	pdf.SetXY(120, y+1)
+	pdf.CellFormat(70, 5, "Verified by", "", 0, "C", false, 0, "")
+
+	w.Header().Set("Content-Type", "application/pdf")
+	if err := pdf.Output(w); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDayReport(t *testing.T) {
+	resetDB(t)
+	present, _ := addFaculty(t, "Ana Present", "Teacher", 100)
+	absent, _ := addFaculty(t, "Ben Absent", "Teacher", 100)
+	multi, _ := addFaculty(t, "Cora Multi", "Teacher", 100)
+	addSession(t, present, "2026-10-14 08:00", "2026-10-14 12:00")
+	addSession(t, multi, "2026-10-14 07:30", "2026-10-14 11:30")
+	addSession(t, multi, "2026-10-14 13:00", "2026-10-14 17:15")
+	// the next day's records stay out of the report
+	addSession(t, absent, "2026-10-15 08:00", "2026-10-15 12:00")
+
+	rows, err := dayReport(at(t, "2026-10-14 00:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]dayReportRow{}
+	for _, r := range rows {
+		got[r.FacultyID] = r
+	}
+	tests := []struct {
+		name             string
+		id               int
+		status           string
+		firstIn, lastOut string
+		hours            float64
+		sessions         int
+	}{
+		{"present", present, "present", "08:00", "12:00", 4, 1},
+		{"absent", absent, "absent", "", "", 0, 0},
+		{"multi-session", multi, "present", "07:30", "17:15", 8.25, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := got[tt.id]
+			if !ok {
+				t.Fatal("faculty missing from the report")
+			}
+			if r.Status() != tt.status || r.FirstIn != tt.firstIn || r.LastOut != tt.lastOut || r.Hours != tt.hours || r.Sessions != tt.sessions {
+				t.Errorf("got %s %q-%q %.2fh %d sessions, want %s %q-%q %.2fh %d sessions",
+					r.Status(), r.FirstIn, r.LastOut, r.Hours, r.Sessions,
+					tt.status, tt.firstIn, tt.lastOut, tt.hours, tt.sessions)
+			}
+		})
+	}
+}
+
+func TestDayReportCSV(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Present", "Teacher", 100)
+	addFaculty(t, "Ben Absent", "Teacher", 100)
+	addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:00")
+
+	rec := httptest.NewRecorder()
+	handleDayReportCSV(rec, httptest.NewRequest("GET", "/report/day.csv?date=2026-10-14", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"FacultyID", "Name", "Role", "FirstIn", "LastOut", "TotalHours", "Status"},
+		{records[1][0], "Ana Present", "Teacher", "08:00", "12:00", "4.00", "present"},
+		{records[2][0], "Ben Absent", "Teacher", "", "", "0.00", "absent"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(records), len(want))
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("row %d col %d: got %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestDayReportBadDate(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleDayReport(rec, httptest.NewRequest("GET", "/report/day?date=14/10/2026", nil))
+	if rec.Code != 400 {
+		t.Errorf("got %d, want 400", rec.Code)
+	}
+}
+
+func TestTimesheetRows(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Present", "Teacher", 100)
+	addSession(t, fid, "2026-02-03 08:00", "2026-02-03 12:30")
+
+	tests := []struct {
+		month string
+		days  int
+	}{
+		{"2026-02-01 00:00", 28},
+		{"2024-02-01 00:00", 29},
+		{"2026-04-01 00:00", 30},
+		{"2026-10-01 00:00", 31},
+	}
+	for _, tt := range tests {
+		t.Run(tt.month[:7], func(t *testing.T) {
+			month := at(t, tt.month)
+			sessions, err := querySessions(month, month.AddDate(0, 1, 0), fid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, _ := timesheetRows(month, summarizeDays(sessions))
+			if len(rows) != tt.days {
+				t.Fatalf("got %d rows, want one per day (%d)", len(rows), tt.days)
+			}
+			for i, row := range rows {
+				if want := month.AddDate(0, 0, i).Format("02 Mon"); row[0] != want {
+					t.Errorf("row %d is %q, want %q", i, row[0], want)
+				}
+			}
+		})
+	}
+
+	month := at(t, "2026-02-01 00:00")
+	sessions, _ := querySessions(month, month.AddDate(0, 1, 0), fid)
+	rows, total := timesheetRows(month, summarizeDays(sessions))
+	if got := rows[2]; got[1] != "08:00" || got[2] != "12:30" || got[3] != "4.50" {
+		t.Errorf("day with records: got %q", got)
+	}
+	if got := rows[3]; got[1] != "" || got[2] != "" || got[3] != "" {
+		t.Errorf("day without records should be blank, got %q", got)
+	}
+	if total != 4.5 {
+		t.Errorf("total %.2f, want 4.50", total)
+	}
+}
+
+func TestTimesheetPDF(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Present", "Teacher", 100)
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?id=" + strconv.Itoa(fid) + "&month=2026-02", 200},
+		{"?id=" + strconv.Itoa(fid) + "&month=Feb", 400},
+		{"?id=999999&month=2026-02", 404},
+		{"", 400},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleTimesheetPDF(rec, httptest.NewRequest("GET", "/timesheet.pdf"+tt.query, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.query, rec.Code, tt.want)
+		}
+		if tt.want == 200 && !strings.HasPrefix(rec.Body.String(), "%PDF") {
+			t.Errorf("%s: body is not a PDF", tt.query)
+		}
+	}
+}
+
+func TestDayModel(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addSession(t, fid, "2026-10-14 08:00", "2026-10-14 12:00")
+	addSession(t, fid, "2026-10-14 13:00", "2026-10-14 17:00")
+
+	tests := []struct {
+		name   string
+		model  string
+		breaks int
+		hours  float64
+	}{
+		{"sum of sessions", "sum-sessions", 0, 8},
+		{"sum ignores break minutes", "sum-sessions", 30, 8},
+		{"span counts the gap", "span", 0, 9},
+		{"span less break minutes", "span", 30, 8.5},
+		{"span less a long break", "span", 90, 7.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &dayModel, tt.model)
+			setVar(t, &breakMinutes, tt.breaks)
+			rows, _, err := computePayroll(at(t, "2026-10-14 00:00"), at(t, "2026-10-15 00:00"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || rows[0].TotalHours != tt.hours {
+				t.Errorf("got %+v, want %.2f hours", rows, tt.hours)
+			}
+		})
+	}
+}
+
+func TestRoundDirection(t *testing.T) {
+	tests := []struct {
+		dir     string
+		minutes int
+		want    int
+	}{
+		{"nearest", 455, 460},
+		{"up", 455, 460},
+		{"down", 455, 450},
+		{"nearest", 452, 450},
+		{"up", 452, 460},
+		{"down", 458, 450},
+		{"nearest", 450, 450},
+		{"up", 450, 450},
+		{"down", 450, 450},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir+" "+strconv.Itoa(tt.minutes), func(t *testing.T) {
+			setVar(t, &roundIncrement, 10)
+			setVar(t, &roundDir, tt.dir)
+			if got := roundMinutes(tt.minutes); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundDirectionCSVMatchesHTML(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addSession(t, fid, "2026-10-14 08:00", "2026-10-14 15:35") // 455 minutes
+	setVar(t, &roundIncrement, 10)
+	for _, tt := range []struct{ dir, want string }{{"nearest", "7.67"}, {"up", "7.67"}, {"down", "7.50"}} {
+		t.Run(tt.dir, func(t *testing.T) {
+			setVar(t, &roundDir, tt.dir)
+			rec := httptest.NewRecorder()
+			handlePayrollCSV(rec, httptest.NewRequest("GET", "/payroll.csv?start=2026-10-14&end=2026-10-14", nil))
+			records, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			col := slices.Index(records[0], "TotalHours")
+			if got := records[1][col]; got != tt.want {
+				t.Errorf("CSV shows %s, want %s", got, tt.want)
+			}
+			rec = httptest.NewRecorder()
+			handlePayroll(rec, httptest.NewRequest("GET", "/payroll?start=2026-10-14&end=2026-10-14", nil))
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("payroll page does not show %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestDayBucketsFollowAppTZ(t *testing.T) {
+	// 02:00 UTC is the evening before in New York but mid-morning in Manila
+	in := time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		zone string
+		day  string
+	}{
+		{"Asia/Manila", "2026-10-15"},
+		{"America/New_York", "2026-10-14"},
+		{"UTC", "2026-10-15"},
+		{"Pacific/Honolulu", "2026-10-14"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setVar(t, &appLoc, loc)
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			// scans store appNow(), so the row carries the configured zone
+			if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, in.In(loc), in.Add(2*time.Hour).In(loc)); err != nil {
+				t.Fatal(err)
+			}
+			sessions, err := querySessions(in.Add(-48*time.Hour), in.Add(48*time.Hour), fid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			days := summarizeDays(sessions)
+			if _, ok := days[tt.day]; !ok || len(days) != 1 {
+				t.Errorf("session bucketed under %v, want %s", keys(days), tt.day)
+			}
+			rows, err := dayReport(at(t, tt.day+" 00:00"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || !rows[0].Present {
+				t.Errorf("day report for %s does not show the session", tt.day)
+			}
+		})
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestNotYetIn(t *testing.T) {
+	resetDB(t)
+	in, _ := addFaculty(t, "Ana In", "Teacher", 100)
+	missing, _ := addFaculty(t, "Ben Missing", "Teacher", 100)
+	open, _ := addFaculty(t, "Cora Open", "Teacher", 100)
+	offToday, _ := addFaculty(t, "Dan Weekends", "Teacher", 100)
+	inactive, _ := addFaculty(t, "Eve Inactive", "Teacher", 100)
+	addSession(t, in, "2026-10-14 07:45", "2026-10-14 12:00")
+	addSession(t, open, "2026-10-14 08:10", "")
+	// yesterday's scan does not count for today
+	addSession(t, missing, "2026-10-13 08:00", "2026-10-13 12:00")
+	if _, err := db.Exec("UPDATE faculty SET work_days=? WHERE id=?", 1<<time.Saturday|1<<time.Sunday, offToday); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE faculty SET active=0 WHERE id=?", inactive); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handleNotYetIn(rec, httptest.NewRequest("GET", "/report/not-yet-in?date=2026-10-14", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got []struct {
+		FacultyID int `json:"faculty_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	listed := map[int]bool{}
+	for _, g := range got {
+		listed[g.FacultyID] = true
+	}
+	tests := []struct {
+		name string
+		id   int
+		want bool
+	}{
+		{"clocked in", in, false},
+		{"not yet in", missing, true},
+		{"clocked in, not out", open, false},
+		{"not scheduled today", offToday, false},
+		{"inactive", inactive, false},
+	}
+	for _, tt := range tests {
+		if listed[tt.id] != tt.want {
+			t.Errorf("%s: listed = %v, want %v", tt.name, listed[tt.id], tt.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handleNotYetIn(rec, httptest.NewRequest("GET", "/report/not-yet-in?date=tomorrow", nil))
+	if rec.Code != 400 {
+		t.Errorf("bad date: got %d, want 400", rec.Code)
+	}
+}
+
+func TestSessionQueriesUseIndex(t *testing.T) {
+	resetDB(t)
+	start := at(t, "2026-01-05 08:00")
+	for f := 0; f < 20; f++ {
+		fid, _ := addFaculty(t, "Faculty "+strconv.Itoa(f), "Teacher", 100)
+		for d := 0; d < 50; d++ {
+			in := start.AddDate(0, 0, d)
+			if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, in, in.Add(4*time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := db.Exec("ANALYZE"); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := at(t, "2026-02-01 00:00"), at(t, "2026-02-16 00:00")
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		index string
+	}{
+		{"one faculty", "SELECT id, faculty_id, in_time, out_time FROM dtr WHERE in_time >= ? AND in_time < ? AND faculty_id = ? ORDER BY in_time",
+			[]any{from, to, 3}, "idx_dtr_faculty_in"},
+		{"everyone", "SELECT id, faculty_id, in_time, out_time FROM dtr WHERE in_time >= ? AND in_time < ? ORDER BY in_time",
+			[]any{from, to}, "idx_dtr_in"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := db.Query("EXPLAIN QUERY PLAN "+tt.query, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Close()
+			var plan []string
+			for rs.Next() {
+				var id, parent, unused int
+				var detail string
+				if err := rs.Scan(&id, &parent, &unused, &detail); err != nil {
+					t.Fatal(err)
+				}
+				plan = append(plan, detail)
+			}
+			got := strings.Join(plan, "; ")
+			if !strings.Contains(got, "INDEX "+tt.index) {
+				t.Errorf("plan does not use %s: %s", tt.index, got)
+			}
+		})
+	}
+}
+
+func TestFacultyMonthlyAPI(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	addSession(t, fid, "2026-01-05 08:00", "2026-01-05 12:00")
+	addSession(t, fid, "2026-01-06 08:00", "2026-01-06 10:30")
+	addSession(t, fid, "2026-03-31 08:00", "2026-03-31 16:00")
+	addSession(t, fid, "2026-12-31 13:00", "2026-12-31 14:00")
+	// other years stay out
+	addSession(t, fid, "2025-12-31 08:00", "2025-12-31 12:00")
+	addSession(t, fid, "2027-01-01 08:00", "2027-01-01 12:00")
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		want  []float64
+	}{
+		{"with records", "?year=2026", 200, []float64{6.5, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{"empty year", "?year=2020", 200, make([]float64, 12)},
+		{"bad year", "?year=20x6", 400, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleFacultyAPI(rec, httptest.NewRequest("GET", "/api/faculty/"+strconv.Itoa(fid)+"/monthly"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got []float64
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 12 {
+				t.Fatalf("got %d months, want 12", len(got))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("%s: got %.2f, want %.2f", time.Month(i+1), got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handleFacultyAPI(rec, httptest.NewRequest("GET", "/api/faculty/999999/monthly?year=2026", nil))
+	if rec.Code != 404 {
+		t.Errorf("unknown faculty: got %d, want 404", rec.Code)
+	}
+}
+
+func TestRoundMinTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		minimum int
+		minutes int
+		want    float64
+	}{
+		{"6 minutes exempt", 15, 6, 0},
+		{"20 minutes paid", 15, 20, 0.5},
+		{"at the threshold", 15, 15, 0.25},
+		{"6 minutes without the exemption", 0, 6, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &roundIncrement, 15)
+			setVar(t, &roundDir, "up")
+			setVar(t, &roundMinTotal, tt.minimum)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			in := at(t, "2026-10-14 08:00")
+			out := in.Add(time.Duration(tt.minutes) * time.Minute)
+			if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)", fid, in, out); err != nil {
+				t.Fatal(err)
+			}
+			rows, _, err := computePayroll(at(t, "2026-10-14 00:00"), at(t, "2026-10-15 00:00"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || rows[0].TotalHours != tt.want || rows[0].Pay != tt.want*100 {
+				t.Errorf("got %+v, want %.2f hours", rows, tt.want)
+			}
+			if got := sessionHours(in, out, 0); got != tt.want {
+				t.Errorf("sessionHours gives %.2f, payroll %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionHoursMatchesPayroll(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		breaks   int
+		minTotal int
+		in, out  string
+	}{
+		{"sum of sessions", "sum-sessions", 30, 0, "08:00", "12:10"},
+		{"span less break minutes", "span", 30, 0, "08:00", "12:10"},
+		{"span with a break longer than the gap", "span", 300, 0, "08:00", "12:10"},
+		{"short session exempt", "sum-sessions", 0, 15, "08:00", "08:06"},
+		{"span short session exempt", "span", 5, 15, "08:00", "08:18"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &dayModel, tt.model)
+			setVar(t, &breakMinutes, tt.breaks)
+			setVar(t, &roundMinTotal, tt.minTotal)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			addSession(t, fid, "2026-10-14 "+tt.in, "2026-10-14 "+tt.out)
+			row := payrollByID(t, "2026-10-14", "2026-10-15")[fid]
+			if got := sessionHours(at(t, "2026-10-14 "+tt.in), at(t, "2026-10-14 "+tt.out), 0); got != row.TotalHours {
+				t.Errorf("sessionHours gives %.2f, payroll %.2f", got, row.TotalHours)
+			}
+		})
+	}
+}
+
+func TestRoundClock(t *testing.T) {
+	tests := []struct {
+		dir  string
+		inc  int
+		scan string
+		want string
+	}{
+		{"nearest", 15, "2026-10-14 08:07:29", "2026-10-14 08:00:00"},
+		{"nearest", 15, "2026-10-14 08:07:30", "2026-10-14 08:15:00"},
+		{"up", 15, "2026-10-14 08:00:01", "2026-10-14 08:15:00"},
+		{"up", 15, "2026-10-14 08:00:00", "2026-10-14 08:00:00"},
+		{"down", 15, "2026-10-14 16:59:59", "2026-10-14 16:45:00"},
+		{"up", 15, "2026-10-14 23:50:00", "2026-10-15 00:00:00"},
+		{"nearest", 6, "2026-10-14 08:03:00", "2026-10-14 08:06:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir+" "+tt.scan, func(t *testing.T) {
+			setVar(t, &roundDir, tt.dir)
+			setVar(t, &roundIncrement, tt.inc)
+			scan, err := time.ParseInLocation("2006-01-02 15:04:05", tt.scan, appLoc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := roundClock(scan).Format("2006-01-02 15:04:05"); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFacultyDaysAPI(t *testing.T) {
+	resetDB(t)
+	setVar(t, &roundIncrement, 15)
+	setVar(t, &roundDir, "nearest")
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	other, _ := addFaculty(t, "Ben Reyes", "Teacher", 100)
+	addSession(t, fid, "2026-10-12 08:00", "2026-10-12 12:00")
+	addSession(t, fid, "2026-10-12 13:00", "2026-10-12 14:30")
+	addSession(t, fid, "2026-10-14 08:00", "2026-10-14 10:00")
+	// outside the range, or someone else's
+	addSession(t, fid, "2026-10-15 08:00", "2026-10-15 12:00")
+	addSession(t, other, "2026-10-13 08:00", "2026-10-13 12:00")
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		want  []dayHours
+	}{
+		{"days with records", "?start=2026-10-12&end=2026-10-14", 200, []dayHours{
+			{"2026-10-12", 5.5, 2},
+			{"2026-10-14", 2, 1},
+		}},
+		{"zero-filled", "?start=2026-10-11&end=2026-10-14&zero_fill=true", 200, []dayHours{
+			{"2026-10-11", 0, 0},
+			{"2026-10-12", 5.5, 2},
+			{"2026-10-13", 0, 0},
+			{"2026-10-14", 2, 1},
+		}},
+		{"no records", "?start=2026-10-01&end=2026-10-03", 200, []dayHours{}},
+		{"bad zero_fill", "?start=2026-10-12&end=2026-10-14&zero_fill=maybe", 400, nil},
+		{"range too long", "?start=2025-01-01&end=2026-10-14", 400, nil},
+		{"end before start", "?start=2026-10-14&end=2026-10-12", 400, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleFacultyAPI(rec, httptest.NewRequest("GET", "/api/faculty/"+strconv.Itoa(fid)+"/days"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got []dayHours
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectMonthPay(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	other, _ := addFaculty(t, "Ben Reyes", "Teacher", 100)
+	addSession(t, fid, "2026-09-07 08:00", "2026-09-07 14:00")
+	addSession(t, fid, "2026-10-05 08:00", "2026-10-05 12:00")
+	addSession(t, fid, "2026-10-08 13:00", "2026-10-08 15:00")
+	addSession(t, other, "2026-10-06 08:00", "2026-10-06 16:00")
+	now := at(t, "2026-10-10 15:00")
+
+	tests := []struct {
+		name     string
+		month    string
+		estimate bool
+		elapsed  int
+		days     int
+		toDate   float64
+		pay      float64
+		err      error
+	}{
+		// 6 hours in 10 of 31 days
+		{"month in progress", "2026-10-01 00:00", true, 10, 31, 600, 1860, nil},
+		{"month over", "2026-09-01 00:00", false, 30, 30, 600, 600, nil},
+		{"month not started", "2026-11-01 00:00", false, 0, 30, 0, 0, errMonthNotStarted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := projectMonthPay(fid, at(t, tt.month), now)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if p.Estimate != tt.estimate || p.DaysElapsed != tt.elapsed || p.DaysInMonth != tt.days {
+				t.Errorf("got estimate=%v over %d of %d days, want %v over %d of %d", p.Estimate, p.DaysElapsed, p.DaysInMonth, tt.estimate, tt.elapsed, tt.days)
+			}
+			if p.PayToDate != tt.toDate || p.ProjectedPay != tt.pay || p.ProjectedHours != tt.pay/100 {
+				t.Errorf("got %.2f to date projected to %.2f (%.2f hours), want %.2f projected to %.2f", p.PayToDate, p.ProjectedPay, p.ProjectedHours, tt.toDate, tt.pay)
+			}
+			if tt.estimate && !strings.Contains(p.Note, "Estimate") {
+				t.Errorf("note %q does not say it is an estimate", p.Note)
+			}
+		})
+	}
+}
+
+func TestFacultyProjectionAPI(t *testing.T) {
+	resetDB(t)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"current month", "", 200},
+		{"past month", "?month=2026-01", 200},
+		{"bad month", "?month=2026-13", 400},
+		{"future month", "?month=" + appNow().AddDate(0, 2, 0).Format("2006-01"), 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleFacultyAPI(rec, httptest.NewRequest("GET", "/api/faculty/"+strconv.Itoa(fid)+"/projection"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.code != 200 {
+				return
+			}
+			var p payProjection
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.FacultyID != fid || p.Estimate != (tt.query == "") {
+				t.Errorf("got %+v", p)
+			}
+		})
+	}
+}
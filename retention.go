@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ---------- DATA RETENTION ----------
+
+// purgeableFilter matches dtr rows clocked in before the cutoff (the first
+// argument) that no open or locked pay period covers; rows in unpaid periods
+// are kept however old they are.
+const purgeableFilter = `in_time < ? AND NOT EXISTS (
+	SELECT 1 FROM locked_periods p
+	WHERE p.status != 'paid' AND substr(dtr.in_time, 1, 10) BETWEEN p.start_date AND p.end_date)`
+
+// handlePurgeDTR deletes dtr rows older than retentionDays. Without
+// confirm=yes it only reports how many rows would go.
+func handlePurgeDTR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	cutoff := dayStart(appNow()).AddDate(0, 0, -retentionDays)
+
+	if r.FormValue("confirm") != "yes" {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM dtr WHERE "+purgeableFilter, cutoff).Scan(&n); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		http.Error(w, fmt.Sprintf("This would delete %d records clocked in before %s; resend with confirm=yes to proceed.",
+			n, cutoff.Format("2006-01-02")), http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.Exec("DELETE FROM dtr WHERE "+purgeableFilter, cutoff)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	n, _ := res.RowsAffected()
+	if _, err := db.Exec("DELETE FROM breaks WHERE dtr_id NOT IN (SELECT id FROM dtr)"); err != nil {
+		logf(r, "purge breaks: %v", err)
+	}
+	audit(r, "dtr.purge", "before "+cutoff.Format("2006-01-02"), fmt.Sprintf("removed %d records", n))
+	setFlash(w, r, fmt.Sprintf("Deleted %d records clocked in before %s.", n, cutoff.Format("2006-01-02")))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
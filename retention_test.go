@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPurgeDTR(t *testing.T) {
+	resetDB(t)
+	setVar(t, &retentionDays, 730)
+	fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	today := dayStart(appNow())
+	day := func(daysAgo int) string { return today.AddDate(0, 0, -daysAgo).Format("2006-01-02") }
+	session := func(daysAgo int) int {
+		return addSession(t, fid, day(daysAgo)+" 08:00", day(daysAgo)+" 12:00")
+	}
+	period := func(daysAgo int, status string) {
+		if _, err := db.Exec("INSERT INTO locked_periods (start_date, end_date, status) VALUES (?,?,?)", day(daysAgo+1), day(daysAgo-1), status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	period(900, "locked")
+	period(950, "open")
+	period(1000, "paid")
+
+	tests := []struct {
+		name string
+		id   int
+		kept bool
+	}{
+		{"old and unlocked", session(800), false},
+		{"old in a paid period", session(1000), false},
+		{"old in a locked period", session(900), true},
+		{"old in an open period", session(950), true},
+		{"recent", session(30), true},
+		{"just inside retention", session(729), true},
+	}
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/purge-dtr", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handlePurgeDTR(rec, req)
+		return rec
+	}
+
+	// without confirmation nothing is deleted, only counted
+	rec := post(nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "delete 2 records") {
+		t.Errorf("unconfirmed: got %d %s", rec.Code, rec.Body)
+	}
+	if n := count(t, "SELECT COUNT(*) FROM dtr"); n != len(tests) {
+		t.Fatalf("unconfirmed purge removed rows: %d left", n)
+	}
+
+	if rec := post(url.Values{"confirm": {"yes"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("confirmed: got %d %s", rec.Code, rec.Body)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if n := count(t, "SELECT COUNT(*) FROM dtr WHERE id=?", tt.id); (n == 1) != tt.kept {
+				t.Errorf("kept = %v, want %v", n == 1, tt.kept)
+			}
+		})
+	}
+	if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action='dtr.purge' AND detail='removed 2 records'"); n != 1 {
+		t.Error("purge was not audited with the count removed")
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- SCAN NONCES ----------
+
+// nonceTTL is how long a scan confirmation page stays valid.
+const nonceTTL = 2 * time.Minute
+
+type nonceEntry struct {
+	token   string
+	expires time.Time
+	used    bool
+}
+
+// nonceStore keeps one-time scan nonces in memory so a refreshed or replayed
+// POST /scan cannot toggle a faculty twice. Used nonces are remembered until
+// they expire so a replay can be told apart from an expired page.
+type nonceStore struct {
+	mu      sync.Mutex
+	entries map[string]*nonceEntry
+}
+
+var scanNonces = &nonceStore{entries: map[string]*nonceEntry{}}
+
+// maxNonces caps the store so a flood of scan pages can't grow it without
+// bound, and maxNoncesPerToken caps the confirmation pages one card can have
+// open at once. When either is reached the oldest nonce is evicted.
+const (
+	maxNonces         = 10000
+	maxNoncesPerToken = 5
+)
+
+// issue creates a fresh nonce bound to token.
+func (s *nonceStore) issue(token string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var oldest, oldestOwn *nonceEntry
+	var oldestKey, oldestOwnKey string
+	own := 0
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+			continue
+		}
+		if oldest == nil || e.expires.Before(oldest.expires) {
+			oldest, oldestKey = e, k
+		}
+		if e.token == token {
+			own++
+			if oldestOwn == nil || e.expires.Before(oldestOwn.expires) {
+				oldestOwn, oldestOwnKey = e, k
+			}
+		}
+	}
+	if own >= maxNoncesPerToken {
+		delete(s.entries, oldestOwnKey)
+	} else if len(s.entries) >= maxNonces {
+		delete(s.entries, oldestKey)
+	}
+	n := randToken()
+	s.entries[n] = &nonceEntry{token: token, expires: now.Add(nonceTTL)}
+	return n
+}
+
+type nonceResult int
+
+const (
+	nonceOK nonceResult = iota
+	nonceUsed
+	nonceInvalid
+)
+
+// consume marks the nonce used if it is valid for token.
+func (s *nonceStore) consume(nonce, token string) nonceResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[nonce]
+	if !ok || e.token != token || time.Now().After(e.expires) {
+		return nonceInvalid
+	}
+	if e.used {
+		return nonceUsed
+	}
+	e.used = true
+	return nonceOK
+}
+
+// ---------- SCAN COOLDOWN ----------
+
+// cooldownFor is the scan cooldown for faculty fid scanning at device: their
+// own override, else scanCooldown. The device id comes from the kiosk's
+// request, so its cooldown may only lengthen that, never shorten it.
+func cooldownFor(fid int, device string) (time.Duration, error) {
+	var own sql.NullInt64
+	if err := db.QueryRow("SELECT scan_cooldown FROM faculty WHERE id=?", fid).Scan(&own); err != nil {
+		return 0, err
+	}
+	cd := scanCooldown
+	if own.Valid {
+		cd = time.Duration(own.Int64) * time.Second
+	}
+	if d, ok := deviceCooldowns[device]; ok && d > cd {
+		cd = d
+	}
+	return cd, nil
+}
+
+// cooldownRemaining is how long faculty fid must still wait before their next
+// clock scan at device; zero or negative means they may scan now.
+func cooldownRemaining(fid int, device string, now time.Time) (time.Duration, error) {
+	cd, err := cooldownFor(fid, device)
+	if err != nil || cd == 0 {
+		return 0, err
+	}
+	// an out-only row, from a missed clock-in, has no in_time
+	var in, out sql.NullTime
+	err = db.QueryRow("SELECT in_time, out_time FROM dtr WHERE faculty_id=? ORDER BY in_time DESC LIMIT 1", fid).Scan(&in, &out)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	last := in.Time
+	if out.Valid && out.Time.After(last) {
+		last = out.Time
+	}
+	return last.Add(cd).Sub(now), nil
+}
+
+// ---------- DAILY HOURS CAP ----------
+
+// dailyHoursCap returns the hours faculty fid has worked in sessions clocked
+// in and out today, net of breaks, and their daily cap: their own, else
+// maxDailyHours. A cap of zero means none.
+func dailyHoursCap(fid int, now time.Time) (worked, limit float64, err error) {
+	var own sql.NullFloat64
+	if err := db.QueryRow("SELECT max_daily_hours FROM faculty WHERE id=?", fid).Scan(&own); err != nil {
+		return 0, 0, err
+	}
+	limit = maxDailyHours
+	if own.Valid {
+		limit = own.Float64
+	}
+	if limit == 0 {
+		return 0, 0, nil
+	}
+	sessions, err := querySessions(dayStart(now), dayStart(now).AddDate(0, 0, 1), fid)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, s := range sessions {
+		if s.Out.Valid && s.Out.Time.After(s.In) {
+			worked += sessionHours(s.In, s.Out.Time, s.minutesOnBreak(s.In, s.Out.Time))
+		}
+	}
+	return worked, limit, nil
+}
+
+// ---------- SCAN SUBMIT ----------
+
+// handleScanSubmit records a clock IN or OUT for a confirmed scan, or the
+// start or end of a break when the scan has a break type.
+func handleScanSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if !clientAllowed(r.RemoteAddr, scanAllowlist) {
+		http.Error(w, "Scanning is not allowed from this network", http.StatusForbidden)
+		return
+	}
+	token := r.FormValue("token")
+
+	var fid int
+	var name, role string
+	err := db.QueryRow("SELECT id,name,role FROM faculty WHERE token=?", token).Scan(&fid, &name, &role)
+	if err != nil {
+		writeUnknownScan(w, r, token)
+		return
+	}
+
+	switch scanNonces.consume(r.FormValue("nonce"), token) {
+	case nonceUsed:
+		writeScanPage(w, http.StatusConflict, "Already processed", name, role,
+			"This scan was already recorded. Scan the card again for a new entry.")
+		return
+	case nonceInvalid:
+		writeScanPage(w, http.StatusBadRequest, "Scan expired", name, role,
+			"This scan page has expired. Please scan the card again.")
+		return
+	}
+
+	now := appNow()
+	if roundAt == "scan" {
+		now = roundClock(now) // stored, shown and compared rounded
+	}
+	if typ := r.FormValue("type"); typ != "" {
+		if !scanTypes[typ] {
+			http.Error(w, "Invalid scan type", http.StatusBadRequest)
+			return
+		}
+		status, err := recordBreak(fid, typ, now)
+		switch {
+		case err == nil && r.FormValue("format") == "receipt":
+			writeScanReceipt(w, name, role, status, now, "")
+		case err == nil:
+			writeScanPage(w, http.StatusOK, status, name, role,
+				fmt.Sprintf("%s at %s", status, now.Format("2006-01-02 15:04:05")))
+		case err == errNotClockedIn, err == errOnBreak, err == errNotOnBreak:
+			writeScanPage(w, http.StatusConflict, "Break not recorded", name, role, err.Error())
+		default:
+			http.Error(w, "DB error", 500)
+		}
+		return
+	}
+
+	device := scanDeviceID(r)
+	wait, err := cooldownRemaining(fid, device, now)
+	if err != nil {
+		http.Error(w, "DB error", 500)
+		return
+	}
+	if wait > 0 {
+		// an admin at the kiosk can let a legitimate quick re-scan through
+		if r.FormValue("bypass_cooldown") != "true" || sessionRole(r) != roleAdmin {
+			writeScanPage(w, http.StatusTooManyRequests, "Scanned too soon", name, role,
+				fmt.Sprintf("Your last scan was just recorded. Please wait %d seconds before scanning again.", int(math.Ceil(wait.Seconds()))))
+			return
+		}
+		audit(r, "scan.bypass_cooldown", fmt.Sprintf("faculty %d", fid), fmt.Sprintf("%ds left", int(math.Ceil(wait.Seconds()))))
+	}
+
+	var dtrID int
+	var inTime sql.NullTime
+	err = db.QueryRow("SELECT id,in_time FROM dtr WHERE faculty_id=? AND out_time IS NULL ORDER BY in_time DESC LIMIT 1", fid).Scan(&dtrID, &inTime)
+
+	status, elapsed := "", ""
+	if err == sql.ErrNoRows {
+		// clock IN, unless the faculty has already worked their daily cap
+		worked, limit, err := dailyHoursCap(fid, now)
+		if err != nil {
+			http.Error(w, "DB error", 500)
+			return
+		}
+		if limit > 0 && worked >= limit {
+			writeScanPage(w, http.StatusConflict, "Daily hours reached", name, role,
+				fmt.Sprintf("You have already worked %s hours today, the most allowed is %s. Please see the office.",
+					formatDuration(worked), formatDuration(limit)))
+			return
+		}
+		// the insert re-checks for an open session itself, so a second scan
+		// racing this one can't leave the faculty with two
+		res, err := db.Exec(`INSERT INTO dtr(faculty_id,in_time,device_id) SELECT ?,?,?
+			WHERE NOT EXISTS (SELECT 1 FROM dtr WHERE faculty_id=? AND out_time IS NULL)`, fid, now, device, fid)
+		if err != nil {
+			http.Error(w, "DB error", 500)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeAlreadyIn(w, r, fid, name, role)
+			return
+		}
+		status = "Clock IN"
+	} else if err == nil {
+		// clock OUT; a break still running ends with the session
+		_, _ = db.Exec("UPDATE dtr SET out_time=?, out_device_id=? WHERE id=?", now, device, dtrID)
+		_, _ = db.Exec("UPDATE breaks SET end_time=? WHERE dtr_id=? AND end_time IS NULL", now, dtrID)
+		status = "Clock OUT"
+		if inTime.Valid {
+			s := []session{{ID: dtrID}}
+			if err := loadBreaks(s); err != nil {
+				logf(r, "scan breaks: %v", err)
+			}
+			elapsed = formatDuration(sessionHours(inTime.Time, now, s[0].minutesOnBreak(inTime.Time, now)))
+		}
+	} else {
+		http.Error(w, "DB error", 500)
+		return
+	}
+
+	if r.FormValue("format") == "receipt" {
+		writeScanReceipt(w, name, role, status, now, elapsed)
+		return
+	}
+	msg := fmt.Sprintf("%s at %s", status, now.Format("2006-01-02 15:04:05"))
+	if elapsed != "" {
+		msg += fmt.Sprintf(" • %s hours this session", elapsed)
+	}
+	if scanMessage != nil {
+		var b strings.Builder
+		err := scanMessage.Execute(&b, struct{ Name, Role, Status, Time, Elapsed string }{
+			name, role, status, now.Format("2006-01-02 15:04:05"), elapsed,
+		})
+		if err == nil {
+			msg = b.String()
+		} else {
+			logf(r, "scan message: %v", err)
+		}
+	}
+	writeScanPage(w, http.StatusOK, status, name, role, msg)
+}
+
+// writeAlreadyIn answers a clock-in that found the faculty's session already
+// open, naming when it was opened.
+func writeAlreadyIn(w http.ResponseWriter, r *http.Request, fid int, name, role string) {
+	var in time.Time
+	err := db.QueryRow("SELECT in_time FROM dtr WHERE faculty_id=? AND out_time IS NULL ORDER BY in_time DESC LIMIT 1", fid).Scan(&in)
+	if err == sql.ErrNoRows {
+		// the racing scan was itself clocked out in the meantime
+		writeScanPage(w, http.StatusConflict, "Already processed", name, role,
+			"Another scan of this card was just recorded. Scan the card again for a new entry.")
+		return
+	}
+	if err != nil {
+		http.Error(w, "DB error", 500)
+		return
+	}
+	var b strings.Builder
+	err = alreadyInMessage.Execute(&b, struct{ Name, Role, Since string }{name, role, in.In(appLoc).Format("15:04")})
+	if err != nil {
+		logf(r, "already-in message: %v", err)
+		b.Reset()
+		b.WriteString("You are already clocked in since " + in.In(appLoc).Format("15:04") + ".")
+	}
+	writeScanPage(w, http.StatusConflict, "Already clocked in", name, role, b.String())
+}
+
+// writeUnknownScan answers a scan of a token no faculty has with a kiosk
+// page explaining what to do, rather than a bare 404. Machine clients should
+// use /api/verify/, which answers unknown tokens with a JSON 404.
+func writeUnknownScan(w http.ResponseWriter, r *http.Request, token string) {
+	if logUnknownScans {
+		if len(token) > 64 { // tokens are at most 64; anything longer is junk
+			token = token[:64] + "…"
+		}
+		logf(r, "scan of unknown token %q from %s", token, r.RemoteAddr)
+	}
+	refresh := ""
+	if scanRedirectURL != "" {
+		refresh = fmt.Sprintf(`<meta http-equiv="refresh" content="%d;url=%s"/>`,
+			scanRedirectSeconds, template.HTMLEscapeString(scanRedirectURL))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, `
+		<!doctype html>
+		<html><head><meta charset="utf-8"/>%s
+		<title>Card Not Recognized | %s</title></head><body>
+		<img src="/logo" style="height:60px"/>
+		<h2>Card not recognized</h2>
+		<p>This QR code isn't registered with %s. Your time was <b>not</b> recorded.</p>
+		<p>If this is your card, it may have been replaced or deactivated. Please ask the administration office for a new card, and have your time entered manually for today.</p>
+		</body></html>
+	`, refresh, template.HTMLEscapeString(appTitle), template.HTMLEscapeString(schoolName))
+}
+
+// maxDeviceIDLen bounds the device id a kiosk may attach to a scan.
+const maxDeviceIDLen = 64
+
+// scanDeviceID reads the optional device_id a kiosk tags its scans with.
+// Any value is accepted; it is only trimmed and truncated.
+func scanDeviceID(r *http.Request) string {
+	id := strings.TrimSpace(r.FormValue("device_id"))
+	if len(id) > maxDeviceIDLen {
+		id = strings.ToValidUTF8(id[:maxDeviceIDLen], "")
+	}
+	return id
+}
+
+// writeScanPage shows the friendly scan result page, returning to
+// scanRedirectURL after a delay when one is configured.
+func writeScanPage(w http.ResponseWriter, code int, title, name, role, msg string) {
+	refresh := ""
+	if scanRedirectURL != "" {
+		refresh = fmt.Sprintf(`<meta http-equiv="refresh" content="%d;url=%s"/>`,
+			scanRedirectSeconds, template.HTMLEscapeString(scanRedirectURL))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `
+		<!doctype html>
+		<html><head><meta charset="utf-8"/>%s
+		<title>Scan Result</title></head><body>
+		<h2>%s</h2>
+		<p><b>%s</b> (%s)</p>
+		<p>%s</p>
+		<p><a href="/">← Back to Home</a></p>
+		</body></html>
+	`, refresh, title, template.HTMLEscapeString(name), template.HTMLEscapeString(role), msg)
+}
+
+// writeScanReceipt renders a successful scan as a slip for a receipt printer
+// (format=receipt): 58 mm wide, monospaced, and printed as soon as it loads.
+// Refused scans still get the normal page, since there is nothing to keep.
+func writeScanReceipt(w http.ResponseWriter, name, role, status string, at time.Time, elapsed string) {
+	refresh := ""
+	if scanRedirectURL != "" {
+		refresh = fmt.Sprintf(`<meta http-equiv="refresh" content="%d;url=%s"/>`,
+			scanRedirectSeconds, template.HTMLEscapeString(scanRedirectURL))
+	}
+	hours := ""
+	if elapsed != "" {
+		hours = fmt.Sprintf("<p>Hours: %s</p>", template.HTMLEscapeString(elapsed))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `
+		<!doctype html>
+		<html><head><meta charset="utf-8"/>%s
+		<title>Scan Receipt</title>
+		<style>
+			@page { size: 58mm auto; margin: 0; }
+			body { width: 54mm; margin: 2mm; font: 11px monospace; }
+			p { margin: 0 0 2px; }
+			.status { font-size: 14px; font-weight: bold; margin: 4px 0; }
+		</style></head><body onload="window.print()">
+		<p>%s</p>
+		<p class="status">%s</p>
+		<p>%s</p>
+		<p>%s</p>
+		<p>%s</p>
+		%s
+		</body></html>
+	`, refresh, template.HTMLEscapeString(schoolName), template.HTMLEscapeString(status),
+		template.HTMLEscapeString(name), template.HTMLEscapeString(role),
+		at.Format("2006-01-02 15:04:05"), hours)
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postScan submits a scan confirmation the way the confirmation page does.
+func postScan(t *testing.T, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/scan", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handleScanSubmit(rec, req)
+	return rec
+}
+
+func TestScanNonce(t *testing.T) {
+	resetDB(t)
+	_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	_, other := addFaculty(t, "Ben Reyes", "Teacher", 100)
+	nonce := scanNonces.issue(token)
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		want  int
+	}{
+		{"fresh", token, nonce, http.StatusOK},
+		{"replayed", token, nonce, http.StatusConflict},
+		{"other card", other, scanNonces.issue(token), http.StatusBadRequest},
+		{"unknown", token, "not-issued", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postScan(t, url.Values{"token": {tt.token}, "nonce": {tt.nonce}})
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if n := count(t, "SELECT COUNT(*) FROM dtr"); n != 1 {
+		t.Errorf("%d sessions recorded, want 1", n)
+	}
+}
+
+func TestNonceStoreBounded(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens int
+		issues int
+		want   int
+	}{
+		{"one card", 1, maxNoncesPerToken + 3, maxNoncesPerToken},
+		{"many cards", maxNonces + 10, maxNonces + 10, maxNonces},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &nonceStore{entries: map[string]*nonceEntry{}}
+			var nonces []string
+			for i := 0; i < tt.issues; i++ {
+				nonces = append(nonces, s.issue(strconv.Itoa(i%tt.tokens)))
+			}
+			if len(s.entries) != tt.want {
+				t.Errorf("store holds %d nonces, want %d", len(s.entries), tt.want)
+			}
+			if _, ok := s.entries[nonces[0]]; ok {
+				t.Error("the oldest nonce was not evicted")
+			}
+			if _, ok := s.entries[nonces[len(nonces)-1]]; !ok {
+				t.Error("the newest nonce was evicted")
+			}
+		})
+	}
+}
+
+func TestScanResultMessage(t *testing.T) {
+	custom := template.Must(template.New("scan-message").Parse("Thank you, {{.Name}}! {{.Status}} recorded."))
+	tests := []struct {
+		name     string
+		redirect string
+		message  *template.Template
+		contains []string
+		absent   []string
+	}{
+		{"default", "", nil, []string{"Clock IN at "}, []string{"http-equiv"}},
+		{"configured", "/kiosk?x=1&y=2", custom,
+			[]string{`<meta http-equiv="refresh" content="3;url=/kiosk?x=1&amp;y=2"/>`, "Thank you, Ana Cruz! Clock IN recorded."},
+			[]string{"Clock IN at "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			setVar(t, &scanRedirectURL, tt.redirect)
+			setVar(t, &scanRedirectSeconds, 3)
+			setVar(t, &scanMessage, tt.message)
+			rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("page lacks %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(body, s) {
+					t.Errorf("page has %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestScanDeviceID(t *testing.T) {
+	tests := []struct {
+		name   string
+		device string
+		want   string
+	}{
+		{"tagged", "lobby-kiosk", "lobby-kiosk"},
+		{"trimmed", "  gate-2 ", "gate-2"},
+		{"empty", "", ""},
+		{"truncated", strings.Repeat("k", maxDeviceIDLen+10), strings.Repeat("k", maxDeviceIDLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &deviceCooldowns, nil)
+			_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}, "device_id": {tt.device}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var got string
+			if err := db.QueryRow("SELECT COALESCE(device_id, '') FROM dtr").Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("stored device %q, want %q", got, tt.want)
+			}
+			events, err := recentScans(5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].DeviceID != tt.want {
+				t.Errorf("recent scans show %+v, want device %q", events, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanDeviceIDOnClockOut(t *testing.T) {
+	resetDB(t)
+	setVar(t, &scanCooldown, 0)
+	_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	for _, device := range []string{"lobby-kiosk", "gate-2"} {
+		rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}, "device_id": {device}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	var in, out string
+	if err := db.QueryRow("SELECT device_id, out_device_id FROM dtr").Scan(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if in != "lobby-kiosk" || out != "gate-2" {
+		t.Errorf("stored devices %q and %q, want lobby-kiosk and gate-2", in, out)
+	}
+	events, err := recentScans(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range events {
+		got[e.Action] = e.DeviceID
+	}
+	if got["IN"] != "lobby-kiosk" || got["OUT"] != "gate-2" {
+		t.Errorf("recent scans show devices %v", got)
+	}
+}
+
+func TestScanElapsedMatchesPayroll(t *testing.T) {
+	tests := []struct {
+		name      string
+		increment int
+		dir       string
+		format    string
+	}{
+		{"quarter hours", 15, "nearest", "decimal"},
+		{"tenths rounded down", 6, "down", "decimal"},
+		{"hh:mm", 15, "up", "hhmm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &scanCooldown, 0)
+			setVar(t, &roundIncrement, tt.increment)
+			setVar(t, &roundDir, tt.dir)
+			setVar(t, &hoursFormat, tt.format)
+			setVar(t, &scanMessage, template.Must(template.New("elapsed").Parse("[{{.Elapsed}}]")))
+			fid, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			if rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}}); rec.Code != http.StatusOK {
+				t.Fatalf("clock in: %d %s", rec.Code, rec.Body)
+			}
+			in := appNow().Add(-(4*time.Hour + 37*time.Minute))
+			if _, err := db.Exec("UPDATE dtr SET in_time=? WHERE faculty_id=?", in, fid); err != nil {
+				t.Fatal(err)
+			}
+			rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("clock out: %d %s", rec.Code, rec.Body)
+			}
+
+			rows, _, err := computePayroll(dayStart(in), dayStart(appNow()).AddDate(0, 0, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d payroll rows", len(rows))
+			}
+			if want := "[" + formatDuration(rows[0].TotalHours) + "]"; !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("scan page shows %s, payroll counts %s", rec.Body, want)
+			}
+		})
+	}
+}
+
+func TestUnknownTokenScan(t *testing.T) {
+	resetDB(t)
+	setVar(t, &schoolName, "San Lorenzo Academy")
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     func() *http.Request
+		ctype   string
+		body    string
+	}{
+		{"scan page", handleScan, func() *http.Request { return httptest.NewRequest("GET", "/scan/nosuchtoken", nil) },
+			"text/html", "Card not recognized"},
+		{"scan submit", handleScanSubmit, func() *http.Request {
+			req := httptest.NewRequest("POST", "/scan", strings.NewReader("token=nosuchtoken&nonce=x"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}, "text/html", "Card not recognized"},
+		{"json", handleVerifyToken, func() *http.Request { return httptest.NewRequest("GET", "/api/verify/nosuchtoken", nil) },
+			"application/json", `"Unknown token"`},
+	}
+	for _, tt := range tests {
+		for _, logged := range []bool{false, true} {
+			t.Run(tt.name+" log="+strconv.FormatBool(logged), func(t *testing.T) {
+				setVar(t, &logUnknownScans, logged)
+				var buf bytes.Buffer
+				log.SetOutput(&buf)
+				defer log.SetOutput(io.Discard)
+
+				rec := httptest.NewRecorder()
+				tt.handler(rec, tt.req())
+				if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Header().Get("Content-Type"), tt.ctype) {
+					t.Fatalf("got %d %s, want 404 %s", rec.Code, rec.Header().Get("Content-Type"), tt.ctype)
+				}
+				if !strings.Contains(rec.Body.String(), tt.body) {
+					t.Errorf("body lacks %q: %s", tt.body, rec.Body)
+				}
+				if tt.ctype == "text/html" && !strings.Contains(rec.Body.String(), "San Lorenzo Academy") {
+					t.Error("kiosk page lacks the school branding")
+				}
+				wantLog := logged && tt.ctype == "text/html"
+				if got := strings.Contains(buf.String(), `scan of unknown token "nosuchtoken"`); got != wantLog {
+					t.Errorf("logged = %v, want %v", got, wantLog)
+				}
+			})
+		}
+	}
+	if n := count(t, "SELECT COUNT(*) FROM dtr"); n != 0 {
+		t.Errorf("%d sessions recorded for an unknown token", n)
+	}
+}
+
+func TestCooldownFor(t *testing.T) {
+	setVar(t, &scanCooldown, 60*time.Second)
+	setVar(t, &deviceCooldowns, map[string]time.Duration{"gate": 120 * time.Second, "library": 0, "desk": 30 * time.Second})
+	tests := []struct {
+		name   string
+		own    any // the faculty's scan_cooldown, nil for none
+		device string
+		want   time.Duration
+	}{
+		{"default", nil, "", 60 * time.Second},
+		{"unknown device", nil, "hallway", 60 * time.Second},
+		{"device lengthens", nil, "gate", 120 * time.Second},
+		{"device cannot disable", nil, "library", 60 * time.Second},
+		{"device cannot shorten", nil, "desk", 60 * time.Second},
+		{"faculty override", 10, "", 10 * time.Second},
+		{"faculty override off", 0, "", 0},
+		{"device cannot shorten the faculty override", 45, "desk", 45 * time.Second},
+		{"device lengthens the faculty override", 10, "desk", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			if _, err := db.Exec("UPDATE faculty SET scan_cooldown=? WHERE id=?", tt.own, fid); err != nil {
+				t.Fatal(err)
+			}
+			got, err := cooldownFor(fid, tt.device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanCooldown(t *testing.T) {
+	tests := []struct {
+		name   string
+		own    any
+		device string
+		role   string // who is signed in at the kiosk, "" for nobody
+		bypass bool
+		want   int
+	}{
+		{"default cooldown", nil, "", "", false, http.StatusTooManyRequests},
+		{"faculty override allows a quick re-scan", 0, "", "", false, http.StatusOK},
+		{"device id cannot lift the cooldown", nil, "library", "", false, http.StatusTooManyRequests},
+		{"admin bypass", nil, "", "admin", true, http.StatusOK},
+		{"bypass needs an admin", nil, "", "viewer", true, http.StatusTooManyRequests},
+		{"bypass needs the flag", nil, "", "admin", false, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &scanCooldown, 60*time.Second)
+			setVar(t, &deviceCooldowns, map[string]time.Duration{"library": 0})
+			fid, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			if _, err := db.Exec("UPDATE faculty SET scan_cooldown=? WHERE id=?", tt.own, fid); err != nil {
+				t.Fatal(err)
+			}
+			if rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}}); rec.Code != http.StatusOK {
+				t.Fatalf("clock in: %d", rec.Code)
+			}
+
+			form := url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}, "device_id": {tt.device}}
+			if tt.bypass {
+				form.Set("bypass_cooldown", "true")
+			}
+			req := httptest.NewRequest("POST", "/scan", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.role != "" {
+				req = signIn(t, req, tt.role)
+			}
+			rec := httptest.NewRecorder()
+			handleScanSubmit(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("re-scan: got %d, want %d", rec.Code, tt.want)
+			}
+			closed := tt.want == http.StatusOK
+			if n := count(t, "SELECT COUNT(*) FROM dtr WHERE out_time IS NOT NULL"); (n == 1) != closed {
+				t.Errorf("session closed = %v, want %v", n == 1, closed)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action='scan.bypass_cooldown'"); (n == 1) != (closed && tt.bypass) {
+				t.Errorf("%d bypass audit entries", n)
+			}
+		})
+	}
+}
+
+func TestScanReceipt(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		typ      string
+		contains []string
+		absent   []string
+	}{
+		{"normal page", "", "", []string{"Clock IN at "}, []string{"@page", "window.print()"}},
+		{"receipt", "receipt", "",
+			[]string{"@page { size: 58mm auto;", `onload="window.print()"`, `<p class="status">Clock IN</p>`, "<p>Ana Cruz</p>", "<p>Teacher</p>", "<p>San Lorenzo Academy</p>"},
+			[]string{"Back to Home", "Clock IN at "}},
+		{"unknown format", "slip", "", []string{"Clock IN at "}, []string{"@page"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &schoolName, "San Lorenzo Academy")
+			setVar(t, &scanMessage, nil)
+			_, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+
+			// the confirmation page carries the format through to the submit
+			get := httptest.NewRecorder()
+			handleScan(get, httptest.NewRequest("GET", "/scan/"+token+"?format="+tt.format, nil))
+			want := ""
+			if tt.format == "receipt" {
+				want = "receipt"
+			}
+			if !strings.Contains(get.Body.String(), `name="format" value="`+want+`"`) {
+				t.Errorf("confirmation page lacks format %q", want)
+			}
+
+			rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}, "format": {tt.format}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			body := rec.Body.String()
+			if tt.format == "receipt" {
+				if day := appNow().Format("2006-01-02 "); !strings.Contains(body, "<p>"+day) {
+					t.Errorf("receipt lacks the scan date %s", day)
+				}
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("page lacks %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(body, s) {
+					t.Errorf("page has %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundAtScan(t *testing.T) {
+	tests := []struct {
+		roundAt string
+		rounded bool
+	}{
+		{"payroll", false},
+		{"scan", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.roundAt, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &scanCooldown, 0)
+			setVar(t, &roundAt, tt.roundAt)
+			setVar(t, &roundIncrement, 15)
+			setVar(t, &roundDir, "down")
+			fid, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			for _, action := range []string{"clock in", "clock out"} {
+				before := appNow()
+				if rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}}); rec.Code != http.StatusOK {
+					t.Fatalf("%s: %d %s", action, rec.Code, rec.Body)
+				}
+				after := appNow()
+				want := [2]time.Time{before.Truncate(time.Second), after}
+				if tt.rounded {
+					want = [2]time.Time{roundClock(before), roundClock(after)}
+				}
+				var got time.Time
+				col := "in_time"
+				if action == "clock out" {
+					col = "out_time"
+				}
+				if err := db.QueryRow("SELECT "+col+" FROM dtr WHERE faculty_id=?", fid).Scan(&got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Before(want[0]) || got.After(want[1]) {
+					t.Errorf("%s stored %s, want between %s and %s", action, got, want[0], want[1])
+				}
+				if isRound := got.Equal(roundClock(got)); tt.rounded && !isRound {
+					t.Errorf("%s stored %s, not on a 15-minute mark", action, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDailyHoursCap(t *testing.T) {
+	tests := []struct {
+		name   string
+		global float64
+		own    any
+		worked time.Duration // closed today before the scan
+		want   int
+	}{
+		{"no cap", 0, nil, 12 * time.Hour, http.StatusOK},
+		{"under the global cap", 10, nil, 9 * time.Hour, http.StatusOK},
+		{"at the global cap", 10, nil, 10 * time.Hour, http.StatusConflict},
+		{"own cap is tighter", 10, 6.0, 7 * time.Hour, http.StatusConflict},
+		{"own cap is looser", 10, 14.0, 12 * time.Hour, http.StatusOK},
+		{"own cap of zero turns it off", 10, 0.0, 12 * time.Hour, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &scanCooldown, 0)
+			setVar(t, &maxDailyHours, tt.global)
+			fid, token := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			if _, err := db.Exec("UPDATE faculty SET max_daily_hours=? WHERE id=?", tt.own, fid); err != nil {
+				t.Fatal(err)
+			}
+			today := dayStart(appNow())
+			// yesterday's hours never count against today
+			if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)",
+				fid, today.Add(-23*time.Hour), today.Add(-time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("INSERT INTO dtr (faculty_id, in_time, out_time) VALUES (?,?,?)",
+				fid, today, today.Add(tt.worked)); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := postScan(t, url.Values{"token": {token}, "nonce": {scanNonces.issue(token)}})
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			open := count(t, "SELECT COUNT(*) FROM dtr WHERE out_time IS NULL")
+			if blocked := tt.want == http.StatusConflict; blocked {
+				if open != 0 {
+					t.Error("clock-in recorded past the cap")
+				}
+				if !strings.Contains(rec.Body.String(), "Daily hours reached") {
+					t.Errorf("page lacks the cap message:\n%s", rec.Body)
+				}
+			} else if open != 1 {
+				t.Error("clock-in not recorded")
+			}
+		})
+	}
+}
+
+func TestAlreadyClockedIn(t *testing.T) {
+	custom := template.Must(template.New("already-in").Parse("{{.Name}}, your session started at {{.Since}}."))
+	tests := []struct {
+		name    string
+		open    string // the racing scan's clock-in, "" if it was clocked out again
+		message *template.Template
+		title   string
+		want    string
+	}{
+		{"default message", "2026-10-14 07:45", nil, "Already clocked in", "You are already clocked in since 07:45."},
+		{"configured message", "2026-10-14 13:05", custom, "Already clocked in", "Ana Cruz, your session started at 13:05."},
+		{"session closed meanwhile", "", nil, "Already processed", "Scan the card again"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			if tt.message != nil {
+				setVar(t, &alreadyInMessage, tt.message)
+			}
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			addSession(t, fid, "2026-10-14 06:00", "2026-10-14 07:00")
+			if tt.open != "" {
+				addSession(t, fid, tt.open, "")
+			}
+			rec := httptest.NewRecorder()
+			writeAlreadyIn(rec, httptest.NewRequest("POST", "/scan", nil), fid, "Ana Cruz", "Teacher")
+			if rec.Code != http.StatusConflict {
+				t.Fatalf("got %d, want %d", rec.Code, http.StatusConflict)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, tt.title) || !strings.Contains(body, tt.want) {
+				t.Errorf("page lacks %q and %q:\n%s", tt.title, tt.want, body)
+			}
+		})
+	}
+}
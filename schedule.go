@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- WEEKLY SCHEDULE ----------
+
+// A faculty's work_days is a bitmask with bit (1 << time.Weekday) set for
+// each scheduled weekday.
+const defaultWorkDays = 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday
+
+// weekdays lists days in display order, Monday first.
+var weekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// worksOn reports whether the schedule includes the weekday.
+func worksOn(mask int, day time.Weekday) bool {
+	return mask&(1<<day) != 0
+}
+
+// scheduleLabel renders a schedule such as "Mon Tue Wed Thu Fri".
+func scheduleLabel(mask int) string {
+	var names []string
+	for _, d := range weekdays {
+		if worksOn(mask, d) {
+			names = append(names, d.String()[:3])
+		}
+	}
+	if len(names) == 0 {
+		return "no scheduled days"
+	}
+	return strings.Join(names, " ")
+}
+
+// parseWorkDays builds a mask from form values holding weekday numbers (0 = Sunday).
+func parseWorkDays(values []string) int {
+	mask := 0
+	for _, v := range values {
+		d, err := strconv.Atoi(v)
+		if err == nil && d >= 0 && d <= 6 {
+			mask |= 1 << d
+		}
+	}
+	return mask
+}
+
+// capSessions returns sessions with each clock-out clamped to shiftEnd
+// ("15:04") on the day of its clock-in, unless its overtime was approved.
+// A session starting after the shift end counts for nothing. The stored
+// times are untouched; only payroll sees the capped copy.
+func capSessions(sessions []session, shiftEnd string) []session {
+	end, err := time.Parse("15:04", shiftEnd)
+	if err != nil {
+		return sessions
+	}
+	out := make([]session, len(sessions))
+	for i, s := range sessions {
+		out[i] = s
+		if !s.Out.Valid || s.OvertimeApproved {
+			continue
+		}
+		limit := dayStart(s.In).Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute)
+		if s.Out.Time.After(limit) {
+			if s.In.After(limit) {
+				limit = s.In
+			}
+			out[i].Out.Time = limit
+		}
+	}
+	return out
+}
+
+func handleFacultySchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", 400)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+	mask := parseWorkDays(r.Form["work_days"])
+	shiftEnd := strings.TrimSpace(r.FormValue("shift_end"))
+	if shiftEnd != "" {
+		if _, err := time.Parse("15:04", shiftEnd); err != nil {
+			http.Error(w, "Invalid shift end, expected HH:MM", http.StatusBadRequest)
+			return
+		}
+	}
+	// a blank cooldown goes back to the kiosk or global default
+	var cooldown sql.NullInt64
+	if v := strings.TrimSpace(r.FormValue("scan_cooldown")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid scan cooldown, expected whole seconds", http.StatusBadRequest)
+			return
+		}
+		cooldown = sql.NullInt64{Int64: int64(n), Valid: true}
+	}
+	// likewise a blank daily cap goes back to maxDailyHours
+	var maxHours sql.NullFloat64
+	if v := strings.TrimSpace(r.FormValue("max_daily_hours")); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h < 0 || h > 24 {
+			http.Error(w, "Invalid daily hours cap, expected 0 to 24 hours", http.StatusBadRequest)
+			return
+		}
+		maxHours = sql.NullFloat64{Float64: h, Valid: true}
+	}
+	if _, err := db.Exec("UPDATE faculty SET work_days=?, shift_end=?, scan_cooldown=?, max_daily_hours=? WHERE id=?", mask, shiftEnd, cooldown, maxHours, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	detail := scheduleLabel(mask)
+	if shiftEnd != "" {
+		detail += ", until " + shiftEnd
+	}
+	if cooldown.Valid {
+		detail += fmt.Sprintf(", cooldown %ds", cooldown.Int64)
+	}
+	if maxHours.Valid {
+		detail += fmt.Sprintf(", max %gh/day", maxHours.Float64)
+	}
+	audit(r, "faculty.schedule", "faculty "+id, detail)
+	setFlash(w, r, "Schedule updated.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
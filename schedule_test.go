@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledAbsence(t *testing.T) {
+	resetDB(t)
+	weekday, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+	weekend, _ := addFaculty(t, "Ben Reyes", "Guard", 100)
+	if _, err := db.Exec("UPDATE faculty SET work_days=? WHERE id=?", 1<<time.Saturday|1<<time.Sunday, weekend); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		day  string
+		id   int
+		want string
+	}{
+		{"absent on a scheduled weekday", "2026-10-14 00:00", weekday, "absent"},
+		{"day off on a Saturday", "2026-10-17 00:00", weekday, "day off"},
+		{"absent on a scheduled Saturday", "2026-10-17 00:00", weekend, "absent"},
+		{"day off on a weekday", "2026-10-14 00:00", weekend, "day off"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := dayReport(at(t, tt.day))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rows {
+				if r.FacultyID == tt.id {
+					if got := r.Status(); got != tt.want {
+						t.Errorf("got %q, want %q", got, tt.want)
+					}
+					return
+				}
+			}
+			t.Error("faculty missing from the report")
+		})
+	}
+}
+
+func TestCapToShiftEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		cap      bool
+		shiftEnd string
+		in, out  string
+		approved bool
+		hours    float64
+	}{
+		{"stayed late", true, "17:00", "08:00", "18:30", false, 9},
+		{"stayed late with approved overtime", true, "17:00", "08:00", "18:30", true, 10.5},
+		{"left on time", true, "17:00", "08:00", "16:00", false, 8},
+		{"started after the shift end", true, "17:00", "17:30", "19:00", false, 0},
+		{"no shift end set", true, "", "08:00", "18:30", false, 10.5},
+		{"rule off", false, "17:00", "08:00", "18:30", false, 10.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			setVar(t, &capToShiftEnd, tt.cap)
+			fid, _ := addFaculty(t, "Ana Cruz", "Teacher", 100)
+			if _, err := db.Exec("UPDATE faculty SET shift_end=? WHERE id=?", tt.shiftEnd, fid); err != nil {
+				t.Fatal(err)
+			}
+			id := addSession(t, fid, "2026-10-14 "+tt.in, "2026-10-14 "+tt.out)
+			if _, err := db.Exec("UPDATE dtr SET overtime_approved=? WHERE id=?", tt.approved, id); err != nil {
+				t.Fatal(err)
+			}
+			row := payrollByID(t, "2026-10-14", "2026-10-15")[fid]
+			if row.TotalHours != tt.hours {
+				t.Errorf("got %.2f hours, want %.2f", row.TotalHours, tt.hours)
+			}
+			// the stored clock-out is never changed
+			if n := count(t, "SELECT COUNT(*) FROM dtr WHERE id=? AND out_time=?", id, at(t, "2026-10-14 "+tt.out)); n != 1 {
+				t.Error("stored clock-out was modified")
+			}
+		})
+	}
+}
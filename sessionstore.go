@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ---------- SESSION STORE ----------
+
+// sessionKey signs session cookies.
+// NOTE: Replace the secret with a strong random key in production
+var sessionKey = []byte("super-secret-key-please-change")
+
+// sessionStores are the accepted SESSION_STORE values: "cookie" keeps the
+// whole session in the signed cookie; "file" and "sqlite" keep it on the
+// server under a random id, so it can be revoked there.
+var sessionStores = map[string]bool{
+	"cookie": true,
+	"file":   true,
+	"sqlite": true,
+}
+
+// sessionOptions are the session cookie attributes: HttpOnly always, Secure
+// and SameSite as configured.
+func sessionOptions() *sessions.Options {
+	return &sessions.Options{
+		Path:     "/",
+		MaxAge:   int(sessionTTL / time.Second),
+		HttpOnly: true,
+		Secure:   cookieSecure,
+		SameSite: cookieSameSite,
+	}
+}
+
+// newSessionStore builds the store sessionStore names, with the session
+// cookie options applied.
+func newSessionStore(opts *sessions.Options) (sessions.Store, error) {
+	switch sessionStore {
+	case "file":
+		if err := os.MkdirAll(sessionDir, 0o700); err != nil {
+			return nil, err
+		}
+		fs := sessions.NewFilesystemStore(sessionDir, sessionKey)
+		fs.Options = opts
+		fs.MaxAge(opts.MaxAge)
+		return fs, nil
+	case "sqlite":
+		codecs := securecookie.CodecsFromPairs(sessionKey)
+		for _, c := range codecs {
+			if sc, ok := c.(*securecookie.SecureCookie); ok {
+				sc.MaxAge(opts.MaxAge)
+			}
+		}
+		return &sqliteStore{codecs: codecs, options: opts}, nil
+	default:
+		cs := sessions.NewCookieStore(sessionKey)
+		cs.Options = opts
+		return cs, nil
+	}
+}
+
+// errCookieSessions is returned by revokeAllSessions under the cookie store,
+// which keeps nothing on the server to revoke.
+var errCookieSessions = errors.New("sign-ins are kept in cookies and can't be revoked; set SESSION_STORE=file or sqlite")
+
+// revokeAllSessions deletes every server-side session, signing everyone out,
+// and returns how many there were.
+func revokeAllSessions() (int, error) {
+	switch sessionStore {
+	case "file":
+		files, err := filepath.Glob(filepath.Join(sessionDir, "session_*"))
+		if err != nil {
+			return 0, err
+		}
+		for _, f := range files {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+		}
+		return len(files), nil
+	case "sqlite":
+		res, err := db.Exec("DELETE FROM sessions")
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		return int(n), nil
+	default:
+		return 0, errCookieSessions
+	}
+}
+
+// handleLogoutAll signs out every account, the caller's included, e.g. after
+// the session key was rotated or a password may have leaked.
+func handleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := revokeAllSessions()
+	if err == errCookieSessions {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	audit(r, "session.logout-all", sessionStore, fmt.Sprintf("revoked %d sessions", n))
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// sqliteStore keeps session values in the sessions table; the cookie holds
+// only the signed session id. Modelled on sessions.FilesystemStore.
+type sqliteStore struct {
+	codecs  []securecookie.Codec
+	options *sessions.Options
+}
+
+var sessionIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func (s *sqliteStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie. An unknown, revoked
+// or expired id yields a fresh session that will get a new id when saved.
+func (s *sqliteStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, c.Value, &id, s.codecs...); err != nil {
+		return session, err
+	}
+	var data string
+	var expires time.Time
+	err = db.QueryRow("SELECT data, expires FROM sessions WHERE id=?", id).Scan(&data, &expires)
+	if err == sql.ErrNoRows || (err == nil && expires.Before(time.Now())) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, data, &session.Values, s.codecs...); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// Save writes the session row and its id cookie; a MaxAge of zero or less
+// deletes both.
+func (s *sqliteStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if _, err := db.Exec("DELETE FROM sessions WHERE id=?", session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		session.ID = sessionIDEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+		// a new session is a cheap moment to drop the expired ones
+		_, _ = db.Exec("DELETE FROM sessions WHERE expires < ?", time.Now())
+	}
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second)
+	if _, err := db.Exec("INSERT OR REPLACE INTO sessions (id, data, expires) VALUES (?,?,?)", session.ID, data, expires); err != nil {
+		return err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+// useSessionStore switches the package store to kind for the test.
+func useSessionStore(t *testing.T, kind string) {
+	t.Helper()
+	setVar(t, &sessionStore, kind)
+	setVar(t, &sessionDir, filepath.Join(t.TempDir(), "sessions"))
+	s, err := newSessionStore(sessionOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	setVar(t, &store, s)
+}
+
+// signedIn reports whether requireLogin lets a new request with r's cookies
+// through. The request is new because gorilla caches a request's sessions.
+func signedIn(r *http.Request) bool {
+	next := httptest.NewRequest("GET", "/", nil)
+	for _, c := range r.Cookies() {
+		next.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	requireLogin(func(w http.ResponseWriter, r *http.Request) {})(rec, next)
+	return rec.Code == http.StatusOK
+}
+
+func TestServerSessionRevoked(t *testing.T) {
+	for _, kind := range []string{"file", "sqlite"} {
+		t.Run(kind, func(t *testing.T) {
+			resetDB(t)
+			useSessionStore(t, kind)
+			mine := signIn(t, httptest.NewRequest("GET", "/", nil), "admin")
+			other := signIn(t, httptest.NewRequest("GET", "/", nil), "admin")
+			if !signedIn(mine) || !signedIn(other) {
+				t.Fatal("fresh sessions rejected")
+			}
+
+			// logging out revokes the session on the server, so replaying
+			// its cookie no longer works
+			handleLogout(httptest.NewRecorder(), mine)
+			if signedIn(mine) {
+				t.Error("logged-out session still accepted")
+			}
+			if !signedIn(other) {
+				t.Fatal("logout revoked another session")
+			}
+		})
+	}
+}
+
+func TestSessionCookieAttributes(t *testing.T) {
+	tests := []struct {
+		name            string
+		env, secure, ss string
+		wantSecure      bool
+		wantSameSite    http.SameSite
+	}{
+		{"development", "", "", "", false, http.SameSiteLaxMode},
+		{"production", "production", "", "", true, http.SameSiteLaxMode},
+		{"production strict", "production", "", "strict", true, http.SameSiteStrictMode},
+		{"production behind plain HTTP", "production", "false", "", false, http.SameSiteLaxMode},
+		{"forced secure in development", "", "true", "strict", true, http.SameSiteStrictMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// registered first so it runs after the environment is restored
+			t.Cleanup(func() { loadConfig() })
+			t.Setenv("ENV", tt.env)
+			t.Setenv("COOKIE_SECURE", tt.secure)
+			t.Setenv("COOKIE_SAMESITE", tt.ss)
+			if err := loadConfig(); err != nil {
+				t.Fatal(err)
+			}
+			cs := sessions.NewCookieStore(sessionKey)
+			cs.Options = sessionOptions()
+			req := httptest.NewRequest("GET", "/", nil)
+			rec := httptest.NewRecorder()
+			session, _ := cs.Get(req, "session")
+			if err := session.Save(req, rec); err != nil {
+				t.Fatal(err)
+			}
+			cookies := rec.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("got %d cookies", len(cookies))
+			}
+			c := cookies[0]
+			if c.Secure != tt.wantSecure || c.SameSite != tt.wantSameSite || !c.HttpOnly {
+				t.Errorf("Secure=%v SameSite=%v HttpOnly=%v, want %v %v true", c.Secure, c.SameSite, c.HttpOnly, tt.wantSecure, tt.wantSameSite)
+			}
+		})
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	for _, kind := range []string{"file", "sqlite"} {
+		t.Run(kind, func(t *testing.T) {
+			resetDB(t)
+			useSessionStore(t, kind)
+			other := signIn(t, httptest.NewRequest("GET", "/", nil), "admin")
+			mine := signIn(t, httptest.NewRequest("POST", "/admin/logout-all", nil), "admin")
+			rec := httptest.NewRecorder()
+			requireAdmin(handleLogoutAll)(rec, mine)
+			if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login" {
+				t.Fatalf("status %d to %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+			}
+			if signedIn(other) || signedIn(mine) {
+				t.Error("session accepted after logout-all")
+			}
+			if kind == "file" {
+				if files, _ := filepath.Glob(filepath.Join(sessionDir, "session_*")); len(files) != 0 {
+					t.Errorf("%d session files left", len(files))
+				}
+			} else if n := count(t, "SELECT COUNT(*) FROM sessions"); n != 0 {
+				t.Errorf("%d session rows left", n)
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action='session.logout-all' AND detail='revoked 2 sessions'"); n != 1 {
+				t.Errorf("%d audit entries", n)
+			}
+		})
+	}
+}
+
+func TestLogoutAllRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		role string
+		want int
+	}{
+		{"cookie store", "cookie", "admin", http.StatusConflict},
+		{"not an admin", "sqlite", "viewer", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDB(t)
+			useSessionStore(t, tt.kind)
+			other := signIn(t, httptest.NewRequest("GET", "/", nil), "admin")
+			rec := httptest.NewRecorder()
+			requireAdmin(handleLogoutAll)(rec, signIn(t, httptest.NewRequest("POST", "/admin/logout-all", nil), tt.role))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if !signedIn(other) {
+				t.Error("refused logout-all still signed a session out")
+			}
+			if n := count(t, "SELECT COUNT(*) FROM audit_log WHERE action='session.logout-all'"); n != 0 {
+				t.Errorf("%d audit entries", n)
+			}
+		})
+	}
+}